@@ -0,0 +1,261 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// packedEncoder is implemented by Value types that know how to encode
+// themselves using Solidity's abi.encodePacked rules, which differ from the
+// padded-to-32-bytes rules EncodeValue/EncodeABI follow: elementary types use
+// their declared byte size rather than a 32-byte word, dynamic bytes and
+// strings are written without a length prefix, and arrays drop the length
+// word (dynamic arrays) and the offset/tail layout while still padding each
+// element to a word.
+//
+// The Value/TupleValue types in encode.go have no such method, since ordinary
+// ABI encoding never needs one; the New*Value constructors below build Values
+// that implement both interfaces, reusing encode.go's own encodeUint/
+// encodeInt/encodeBool/encodeAddress/encodeBytes/encodeFixedBytes for
+// EncodeABI so the two encodings can never disagree about a type's layout.
+type packedEncoder interface {
+	EncodePacked() ([]byte, error)
+}
+
+// EncodePacked encodes vals the way Solidity's abi.encodePacked does: unlike
+// EncodeValues, elementary types are encoded using their declared byte size
+// rather than padded to a 32-byte word, dynamic bytes and strings are
+// concatenated raw without a length prefix, and arrays are encoded one
+// standard 32-byte word per element but without an outer length word.
+//
+// This is the byte stream that signature schemes such as EIP-191, Permit2
+// witnesses, and Merkle-tree leaf hashing run through keccak256.
+//
+// vals must be built with the New*Value constructors in this file (or any
+// other Value that also implements packedEncoder); a Value that only
+// implements EncodeABI/IsDynamic is rejected, since padded ABI words cannot
+// be reinterpreted as packed bytes without knowing the declared type size.
+func EncodePacked(vals ...Value) ([]byte, error) {
+	var buf []byte
+	for i, v := range vals {
+		p, ok := v.(packedEncoder)
+		if !ok {
+			return nil, fmt.Errorf("abi: argument %d: %T does not support packed encoding", i, v)
+		}
+		b, err := p.EncodePacked()
+		if err != nil {
+			return nil, fmt.Errorf("abi: argument %d: %w", i, err)
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// UintValue is a Value for Solidity's uintN types, where Size is N in bits
+// (8-256, a multiple of 8).
+type UintValue struct {
+	Size int
+	Val  *big.Int
+}
+
+func NewUintValue(size int, val *big.Int) *UintValue { return &UintValue{Size: size, Val: val} }
+
+func (v *UintValue) EncodeABI() (Words, error) { return encodeUint(v.Val, v.Size) }
+func (v *UintValue) IsDynamic() bool           { return false }
+
+// EncodePacked renders Val as its exact Size/8-byte big-endian unsigned
+// representation, unlike EncodeABI, which always pads to a 32-byte word.
+func (v *UintValue) EncodePacked() ([]byte, error) {
+	words, err := encodeUint(v.Val, v.Size)
+	if err != nil {
+		return nil, err
+	}
+	b := words.Bytes()
+	return b[len(b)-v.Size/8:], nil
+}
+
+// IntValue is a Value for Solidity's intN types, where Size is N in bits
+// (8-256, a multiple of 8).
+type IntValue struct {
+	Size int
+	Val  *big.Int
+}
+
+func NewIntValue(size int, val *big.Int) *IntValue { return &IntValue{Size: size, Val: val} }
+
+func (v *IntValue) EncodeABI() (Words, error)     { return encodeInt(v.Val, v.Size) }
+func (v *IntValue) IsDynamic() bool               { return false }
+func (v *IntValue) EncodePacked() ([]byte, error) { return packedInt(v.Val, v.Size) }
+
+// BoolValue is a Value for Solidity's bool type.
+type BoolValue struct {
+	Val bool
+}
+
+func NewBoolValue(val bool) *BoolValue { return &BoolValue{Val: val} }
+
+func (v *BoolValue) EncodeABI() (Words, error) { return encodeBool(v.Val), nil }
+func (v *BoolValue) IsDynamic() bool           { return false }
+
+func (v *BoolValue) EncodePacked() ([]byte, error) {
+	if v.Val {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+// AddressValue is a Value for Solidity's address type.
+type AddressValue struct {
+	Val types.Address
+}
+
+func NewAddressValue(val types.Address) *AddressValue { return &AddressValue{Val: val} }
+
+func (v *AddressValue) EncodeABI() (Words, error)     { return encodeAddress(v.Val) }
+func (v *AddressValue) IsDynamic() bool               { return false }
+func (v *AddressValue) EncodePacked() ([]byte, error) { return v.Val.Bytes(), nil }
+
+// BytesValue is a Value for Solidity's dynamic bytes type.
+type BytesValue struct {
+	Val []byte
+}
+
+func NewBytesValue(val []byte) *BytesValue { return &BytesValue{Val: val} }
+
+func (v *BytesValue) EncodeABI() (Words, error)     { return encodeBytes(v.Val) }
+func (v *BytesValue) IsDynamic() bool               { return true }
+func (v *BytesValue) EncodePacked() ([]byte, error) { return v.Val, nil }
+
+// FixedBytesValue is a Value for Solidity's bytesN types, where Size is N in
+// bytes (1-32).
+type FixedBytesValue struct {
+	Size int
+	Val  []byte
+}
+
+func NewFixedBytesValue(size int, val []byte) *FixedBytesValue {
+	return &FixedBytesValue{Size: size, Val: val}
+}
+
+func (v *FixedBytesValue) EncodeABI() (Words, error) { return encodeFixedBytes(v.Val, v.Size) }
+func (v *FixedBytesValue) IsDynamic() bool           { return false }
+
+func (v *FixedBytesValue) EncodePacked() ([]byte, error) {
+	if len(v.Val) > v.Size {
+		return nil, fmt.Errorf("abi: cannot encode %d bytes to bytes%d", len(v.Val), v.Size)
+	}
+	out := make([]byte, v.Size)
+	copy(out, v.Val)
+	return out, nil
+}
+
+// StringValue is a Value for Solidity's dynamic string type.
+type StringValue struct {
+	Val string
+}
+
+func NewStringValue(val string) *StringValue { return &StringValue{Val: val} }
+
+func (v *StringValue) EncodeABI() (Words, error)     { return encodeBytes([]byte(v.Val)) }
+func (v *StringValue) IsDynamic() bool               { return true }
+func (v *StringValue) EncodePacked() ([]byte, error) { return []byte(v.Val), nil }
+
+// ArrayValue is a Value for Solidity's dynamic array type, T[].
+type ArrayValue struct {
+	Elems []Value
+}
+
+func NewArrayValue(elems ...Value) *ArrayValue { return &ArrayValue{Elems: elems} }
+
+func (v *ArrayValue) EncodeABI() (Words, error)     { return encodeArray(v.Elems) }
+func (v *ArrayValue) IsDynamic() bool               { return true }
+func (v *ArrayValue) EncodePacked() ([]byte, error) { return encodePackedElems(v.Elems) }
+
+// FixedArrayValue is a Value for Solidity's fixed-size array type, T[N].
+type FixedArrayValue struct {
+	Elems []Value
+}
+
+func NewFixedArrayValue(elems ...Value) *FixedArrayValue { return &FixedArrayValue{Elems: elems} }
+
+func (v *FixedArrayValue) EncodeABI() (Words, error)     { return encodeFixedArray(v.Elems) }
+func (v *FixedArrayValue) IsDynamic() bool               { return false }
+func (v *FixedArrayValue) EncodePacked() ([]byte, error) { return encodePackedElems(v.Elems) }
+
+// encodePackedElems renders elems the way Solidity packs an array: Solidity
+// does not compact array elements, so each keeps its full, padded 32-byte ABI
+// word, but (unlike encodeArray/encodeFixedArray) there is no outer length
+// word or tail/offset layout — the words are just concatenated.
+func encodePackedElems(elems []Value) ([]byte, error) {
+	var buf []byte
+	for i, e := range elems {
+		words, err := e.EncodeABI()
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		buf = append(buf, words.Bytes()...)
+	}
+	return buf, nil
+}
+
+// SoliditySha3 computes keccak256(abi.encodePacked(vals...)) the way
+// web3.js's soliditySha3 does: the Solidity type of each value is inferred
+// from its Go type rather than declared explicitly.
+//
+// Supported Go types are types.Address, types.Hash, []byte (Solidity
+// "bytes"), string ("string"), bool, *big.Int, int, int64, uint64
+// ("int256"/"uint256"). Any other type is encoded as an empty byte string.
+func SoliditySha3(vals ...any) types.Hash {
+	var buf []byte
+	for _, v := range vals {
+		buf = append(buf, soliditySha3Bytes(v)...)
+	}
+	return crypto.Keccak256(buf)
+}
+
+// soliditySha3Bytes returns the abi.encodePacked representation of val.
+func soliditySha3Bytes(val any) []byte {
+	switch v := val.(type) {
+	case types.Address:
+		return v.Bytes()
+	case types.Hash:
+		return v.Bytes()
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case bool:
+		if v {
+			return []byte{1}
+		}
+		return []byte{0}
+	case *big.Int:
+		b, _ := packedInt(v, 256)
+		return b
+	case int:
+		b, _ := packedInt(big.NewInt(int64(v)), 256)
+		return b
+	case int64:
+		b, _ := packedInt(big.NewInt(v), 256)
+		return b
+	case uint64:
+		b, _ := packedInt(new(big.Int).SetUint64(v), 256)
+		return b
+	default:
+		return nil
+	}
+}
+
+// packedInt renders val as the two's-complement representation of an intN
+// of the given bit size, occupying exactly size/8 bytes — unlike
+// encodeInt/encodeUint, which pad that representation into a 32-byte Word.
+func packedInt(val *big.Int, size int) ([]byte, error) {
+	x := newIntX(size)
+	if err := x.SetBigInt(val); err != nil {
+		return nil, err
+	}
+	return x.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package abi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+func TestEncodePacked_Scalars(t *testing.T) {
+	addr := types.Address{1, 2, 3}
+	got, err := EncodePacked(
+		NewUintValue(16, big.NewInt(1)),
+		NewBoolValue(true),
+		NewAddressValue(addr),
+		NewBytesValue([]byte("hi")),
+	)
+	if err != nil {
+		t.Fatalf("EncodePacked: %v", err)
+	}
+
+	var want []byte
+	want = append(want, 0x00, 0x01) // uint16(1), exactly 2 bytes, no padding
+	want = append(want, 0x01)       // bool true, exactly 1 byte
+	want = append(want, addr.Bytes()...)
+	want = append(want, "hi"...)
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodePacked = %x, want %x", got, want)
+	}
+}
+
+func TestEncodePacked_FixedBytesPadsToSize(t *testing.T) {
+	got, err := EncodePacked(NewFixedBytesValue(4, []byte{1, 2}))
+	if err != nil {
+		t.Fatalf("EncodePacked: %v", err)
+	}
+	want := []byte{1, 2, 0, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodePacked = %x, want %x", got, want)
+	}
+}
+
+func TestEncodePacked_ArrayElementsStayPadded(t *testing.T) {
+	got, err := EncodePacked(NewArrayValue(
+		NewUintValue(8, big.NewInt(1)),
+		NewUintValue(8, big.NewInt(2)),
+	))
+	if err != nil {
+		t.Fatalf("EncodePacked: %v", err)
+	}
+	// Unlike a bare uint8, an array element keeps its full 32-byte ABI word,
+	// and there is no outer length word the way encodeArray would add one.
+	want := make([]byte, 64)
+	want[31] = 1
+	want[63] = 2
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodePacked = %x, want %x", got, want)
+	}
+}
+
+func TestEncodePacked_RejectsPlainValue(t *testing.T) {
+	if _, err := EncodePacked(&TupleValue{}); err == nil {
+		t.Error("expected an error for a Value without packed encoding support")
+	}
+}
+
+func TestSoliditySha3(t *testing.T) {
+	got := SoliditySha3(uint64(1), "hi", true)
+	want := crypto.Keccak256(append(append(packedMust(t, 1), "hi"...), 1))
+	if got != want {
+		t.Errorf("SoliditySha3 = %x, want %x", got, want)
+	}
+}
+
+func packedMust(t *testing.T, v int64) []byte {
+	t.Helper()
+	b, err := packedInt(big.NewInt(v), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,303 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// domainTypeName is the reserved EIP-712 struct name for the domain
+// separator.
+const domainTypeName = "EIP712Domain"
+
+// HashTypedData computes the EIP-712 signing hash of typedData:
+//
+//	keccak256(0x1901 || hashStruct(domain) || hashStruct(primaryType, message))
+func HashTypedData(typedData *types.TypedData) (types.Hash, error) {
+	domainTypes, domainData := domainTypeAndData(typedData.Domain)
+	allTypes := make(types.TypedDataTypes, len(typedData.Types)+1)
+	for k, v := range typedData.Types {
+		allTypes[k] = v
+	}
+	allTypes[domainTypeName] = domainTypes
+
+	domainSeparator, err := hashStruct(domainTypeName, domainData, allTypes)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("abi: failed to hash EIP-712 domain: %w", err)
+	}
+	messageHash, err := hashStruct(typedData.PrimaryType, typedData.Message, typedData.Types)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("abi: failed to hash EIP-712 message: %w", err)
+	}
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, messageHash), nil
+}
+
+// domainTypeAndData builds the EIP712Domain type definition and message data
+// from the set fields of domain. Unset fields are omitted from both.
+func domainTypeAndData(domain types.TypedDataDomain) ([]types.TypedDataField, map[string]any) {
+	var fields []types.TypedDataField
+	data := make(map[string]any)
+	add := func(name, typ string, val any) {
+		fields = append(fields, types.TypedDataField{Name: name, Type: typ})
+		data[name] = val
+	}
+	if domain.Name != "" {
+		add("name", "string", domain.Name)
+	}
+	if domain.Version != "" {
+		add("version", "string", domain.Version)
+	}
+	if domain.ChainID != nil {
+		add("chainId", "uint256", domain.ChainID)
+	}
+	if domain.VerifyingContract != nil {
+		add("verifyingContract", "address", *domain.VerifyingContract)
+	}
+	if domain.Salt != nil {
+		add("salt", "bytes32", domain.Salt)
+	}
+	return fields, data
+}
+
+// hashStruct computes keccak256(typeHash(typeName) || encodeData(typeName, data)).
+func hashStruct(typeName string, data map[string]any, typs types.TypedDataTypes) ([]byte, error) {
+	th, err := typeHash(typeName, typs)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := encodeData(typeName, data, typs)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(th, enc).Bytes(), nil
+}
+
+// typeHash computes keccak256(encodeType(typeName)).
+func typeHash(typeName string, typs types.TypedDataTypes) ([]byte, error) {
+	enc, err := encodeType(typeName, typs)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256([]byte(enc)).Bytes(), nil
+}
+
+// encodeType renders the EIP-712 type string for typeName: the struct itself
+// followed by every struct type it references (directly or transitively),
+// sorted alphabetically.
+func encodeType(typeName string, typs types.TypedDataTypes) (string, error) {
+	deps := map[string]bool{}
+	collectDeps(typeName, typs, deps)
+	delete(deps, typeName)
+
+	ordered := make([]string, 0, len(deps))
+	for d := range deps {
+		ordered = append(ordered, d)
+	}
+	sort.Strings(ordered)
+	ordered = append([]string{typeName}, ordered...)
+
+	var sb strings.Builder
+	for _, name := range ordered {
+		fields, ok := typs[name]
+		if !ok {
+			return "", fmt.Errorf("abi: unknown EIP-712 type %q", name)
+		}
+		sb.WriteString(name)
+		sb.WriteByte('(')
+		for i, f := range fields {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(f.Type)
+			sb.WriteByte(' ')
+			sb.WriteString(f.Name)
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String(), nil
+}
+
+// collectDeps recursively adds typeName and every struct type referenced by
+// its fields to seen.
+func collectDeps(typeName string, typs types.TypedDataTypes, seen map[string]bool) {
+	if seen[typeName] {
+		return
+	}
+	fields, ok := typs[typeName]
+	if !ok {
+		return
+	}
+	seen[typeName] = true
+	for _, f := range fields {
+		base := baseType(f.Type)
+		if _, ok := typs[base]; ok {
+			collectDeps(base, typs, seen)
+		}
+	}
+}
+
+// encodeData encodes the fields of typeName in declaration order, producing
+// the concatenated 32-byte words hashed by hashStruct.
+func encodeData(typeName string, data map[string]any, typs types.TypedDataTypes) ([]byte, error) {
+	fields, ok := typs[typeName]
+	if !ok {
+		return nil, fmt.Errorf("abi: unknown EIP-712 type %q", typeName)
+	}
+	var buf []byte
+	for _, f := range fields {
+		enc, err := encodeTypedValue(f.Type, data[f.Name], typs)
+		if err != nil {
+			return nil, fmt.Errorf("abi: field %q: %w", f.Name, err)
+		}
+		buf = append(buf, enc...)
+	}
+	return buf, nil
+}
+
+// encodeTypedValue encodes a single EIP-712 value to a 32-byte word,
+// recursing into struct and array types as needed.
+func encodeTypedValue(fieldType string, val any, typs types.TypedDataTypes) ([]byte, error) {
+	if base, elemType, ok := arrayType(fieldType); ok {
+		_ = base
+		elems, ok := val.([]any)
+		if !ok {
+			return nil, fmt.Errorf("abi: expected []any for %q", fieldType)
+		}
+		var buf []byte
+		for _, e := range elems {
+			enc, err := encodeTypedValue(elemType, e, typs)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, enc...)
+		}
+		return crypto.Keccak256(buf).Bytes(), nil
+	}
+	if _, ok := typs[fieldType]; ok {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("abi: expected map[string]any for %q", fieldType)
+		}
+		return hashStruct(fieldType, m, typs)
+	}
+	switch {
+	case fieldType == "string":
+		s, _ := val.(string)
+		return crypto.Keccak256([]byte(s)).Bytes(), nil
+	case fieldType == "bytes":
+		return crypto.Keccak256(toBytes(val)).Bytes(), nil
+	case fieldType == "bool":
+		b, _ := val.(bool)
+		return encodeBool(b).Bytes(), nil
+	case fieldType == "address":
+		addr, _ := val.(types.Address)
+		words, err := encodeAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		return words.Bytes(), nil
+	case strings.HasPrefix(fieldType, "uint"):
+		size, err := intTypeSize(fieldType, "uint")
+		if err != nil {
+			return nil, err
+		}
+		words, err := encodeUint(toBigInt(val), size)
+		if err != nil {
+			return nil, err
+		}
+		return words.Bytes(), nil
+	case strings.HasPrefix(fieldType, "int"):
+		size, err := intTypeSize(fieldType, "int")
+		if err != nil {
+			return nil, err
+		}
+		words, err := encodeInt(toBigInt(val), size)
+		if err != nil {
+			return nil, err
+		}
+		return words.Bytes(), nil
+	case strings.HasPrefix(fieldType, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(fieldType, "bytes"))
+		if err != nil {
+			return nil, fmt.Errorf("abi: invalid fixed bytes type %q", fieldType)
+		}
+		words, err := encodeFixedBytes(toBytes(val), size)
+		if err != nil {
+			return nil, err
+		}
+		return words.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("abi: unsupported EIP-712 type %q", fieldType)
+	}
+}
+
+// arrayType reports whether fieldType is an array type (either "T[]" or
+// "T[N]"), returning the outer type and its element type.
+func arrayType(fieldType string) (outer, elem string, ok bool) {
+	i := strings.LastIndexByte(fieldType, '[')
+	if i < 0 || !strings.HasSuffix(fieldType, "]") {
+		return "", "", false
+	}
+	return fieldType, fieldType[:i], true
+}
+
+// baseType strips all array suffixes from a type name.
+func baseType(fieldType string) string {
+	if i := strings.IndexByte(fieldType, '['); i >= 0 {
+		return fieldType[:i]
+	}
+	return fieldType
+}
+
+// intTypeSize extracts the bit size from a "uintN"/"intN" type name,
+// defaulting to 256 for the bare "uint"/"int" aliases.
+func intTypeSize(fieldType, prefix string) (int, error) {
+	suffix := strings.TrimPrefix(fieldType, prefix)
+	if suffix == "" {
+		return 256, nil
+	}
+	size, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0, fmt.Errorf("abi: invalid integer type %q", fieldType)
+	}
+	return size, nil
+}
+
+// toBigInt converts common numeric representations to a *big.Int.
+func toBigInt(val any) *big.Int {
+	switch v := val.(type) {
+	case *big.Int:
+		return v
+	case int64:
+		return big.NewInt(v)
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case int:
+		return big.NewInt(int64(v))
+	case string:
+		n, _ := new(big.Int).SetString(v, 0)
+		if n == nil {
+			n = new(big.Int)
+		}
+		return n
+	default:
+		return new(big.Int)
+	}
+}
+
+// toBytes converts common byte representations to a []byte.
+func toBytes(val any) []byte {
+	switch v := val.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return nil
+	}
+}
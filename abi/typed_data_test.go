@@ -0,0 +1,124 @@
+package abi
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defiweb/go-eth/types"
+)
+
+// mailTypedData returns a payload with the types and field names of the
+// "Mail" example from the EIP-712 specification (nested struct, no arrays),
+// with placeholder addresses. The tests below check properties of
+// HashTypedData on it, not the specification's published hash.
+func mailTypedData() *types.TypedData {
+	verifyingContract := types.Address{0xCC, 0xCC}
+	return &types.TypedData{
+		Domain: types.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainID:           big.NewInt(1),
+			VerifyingContract: &verifyingContract,
+		},
+		PrimaryType: "Mail",
+		Types: types.TypedDataTypes{
+			"Person": {
+				{Name: "name", Type: "string"},
+				{Name: "wallet", Type: "address"},
+			},
+			"Mail": {
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		Message: map[string]any{
+			"from": map[string]any{
+				"name":   "Cow",
+				"wallet": types.Address{0x11, 0x11},
+			},
+			"to": map[string]any{
+				"name":   "Bob",
+				"wallet": types.Address{0x22, 0x22},
+			},
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func TestHashTypedData_Deterministic(t *testing.T) {
+	td := mailTypedData()
+	h1, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+	h2, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("HashTypedData is not deterministic: %x != %x", h1, h2)
+	}
+}
+
+func TestHashTypedData_MessageChangesHash(t *testing.T) {
+	td := mailTypedData()
+	base, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+
+	td.Message["contents"] = "Hello, Alice!"
+	changed, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+	if base == changed {
+		t.Error("HashTypedData did not change when the message did")
+	}
+}
+
+func TestHashTypedData_DomainChangesHash(t *testing.T) {
+	td := mailTypedData()
+	base, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+
+	td.Domain.ChainID = big.NewInt(2)
+	changed, err := HashTypedData(td)
+	if err != nil {
+		t.Fatalf("HashTypedData: %v", err)
+	}
+	if base == changed {
+		t.Error("HashTypedData did not change when the domain did")
+	}
+}
+
+func TestHashTypedData_UnknownType(t *testing.T) {
+	td := mailTypedData()
+	td.PrimaryType = "Nonexistent"
+	if _, err := HashTypedData(td); err == nil {
+		t.Error("expected an error for an unknown primary type")
+	}
+}
+
+func TestEncodeType_OrdersReferencedTypesAlphabetically(t *testing.T) {
+	typs := types.TypedDataTypes{
+		"Mail": {
+			{Name: "from", Type: "Person"},
+			{Name: "to", Type: "Person"},
+		},
+		"Person": {
+			{Name: "name", Type: "string"},
+		},
+	}
+	got, err := encodeType("Mail", typs)
+	if err != nil {
+		t.Fatalf("encodeType: %v", err)
+	}
+	want := "Mail(Person from,Person to)Person(string name)"
+	if got != want {
+		t.Errorf("encodeType = %q, want %q", got, want)
+	}
+}
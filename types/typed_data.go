@@ -0,0 +1,33 @@
+package types
+
+import "math/big"
+
+// TypedDataField describes a single field of an EIP-712 typed struct.
+type TypedDataField struct {
+	Name string
+	Type string
+}
+
+// TypedDataTypes maps a struct type name to its ordered list of fields, as
+// used by the "types" section of an EIP-712 payload.
+type TypedDataTypes map[string][]TypedDataField
+
+// TypedDataDomain is the "domain" separator of an EIP-712 payload. Fields are
+// optional; only the ones that are set are included in the domain type and
+// hash.
+type TypedDataDomain struct {
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract *Address
+	Salt              []byte
+}
+
+// TypedData is an EIP-712 typed-data payload, ready to be hashed and signed
+// with (*wallet.PrivateKey).SignTypedData.
+type TypedData struct {
+	Domain      TypedDataDomain
+	PrimaryType string
+	Types       TypedDataTypes
+	Message     map[string]any
+}
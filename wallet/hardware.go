@@ -0,0 +1,176 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/defiweb/go-eth/types"
+)
+
+var (
+	ErrDeviceNotFound = errors.New("wallet: hardware wallet not found at path")
+	ErrDeviceResponse = errors.New("wallet: unexpected hardware wallet response")
+	ErrUserRejected   = errors.New("wallet: user rejected the request on the device")
+	// ErrRawHashSigningUnsupported is returned by LedgerKey.SignHash and
+	// TrezorKey.SignHash. Both devices' Ethereum apps deliberately have no
+	// instruction to sign an arbitrary 32-byte digest blind (it would let a
+	// compromised host get a device to sign anything, including a
+	// transaction hash, without the owner ever seeing what they're
+	// approving) — only a wrapped personal message, typed data, or a full
+	// transaction. Rather than silently signing something other than hash
+	// under the SignHash name, these types report the gap instead.
+	ErrRawHashSigningUnsupported = errors.New("wallet: hardware wallet cannot sign a raw hash; use SignMessage or SignTransaction instead")
+	apduChunkSize                = 255
+	hidReportSize                = 64
+	hidChannelID                 = uint16(0x0101)
+	hidTagAPDU                   = byte(0x05)
+	statusWordOK                 = uint16(0x9000)
+	statusWordRejected           = uint16(0x6985)
+)
+
+// hidDevice is the subset of github.com/karalabe/hid's Device used to talk
+// to a hardware wallet. It is satisfied by the real USB HID device as well
+// as by mocks in tests.
+type hidDevice interface {
+	Write(b []byte) (int, error)
+	Read(b []byte) (int, error)
+	Close() error
+}
+
+// apduCommand is a single Ethereum app APDU request, identified by its class
+// and instruction bytes, as used by the Ledger Ethereum app. TrezorKey does
+// not use it: Trezor speaks protobuf messages instead (see trezor.go).
+type apduCommand struct {
+	CLA  byte
+	INS  byte
+	P1   byte
+	P2   byte
+	Data []byte
+}
+
+// exchangeAPDU frames cmd using the vendor's HID transport protocol, writes
+// it to dev in hidReportSize chunks, and returns the APDU response data with
+// the trailing two-byte status word stripped off. An error is returned if
+// the status word does not indicate success.
+func exchangeAPDU(dev hidDevice, cmd apduCommand) ([]byte, error) {
+	apdu := make([]byte, 0, 5+len(cmd.Data))
+	apdu = append(apdu, cmd.CLA, cmd.INS, cmd.P1, cmd.P2, byte(len(cmd.Data)))
+	apdu = append(apdu, cmd.Data...)
+
+	if err := writeAPDU(dev, apdu); err != nil {
+		return nil, err
+	}
+	resp, err := readAPDU(dev)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 {
+		return nil, ErrDeviceResponse
+	}
+	sw := binary.BigEndian.Uint16(resp[len(resp)-2:])
+	data := resp[:len(resp)-2]
+	switch sw {
+	case statusWordOK:
+		return data, nil
+	case statusWordRejected:
+		return nil, ErrUserRejected
+	default:
+		return nil, fmt.Errorf("%w: status word %04x", ErrDeviceResponse, sw)
+	}
+}
+
+// writeAPDU wraps apdu in the vendor HID framing (channel ID, APDU tag,
+// sequence number and, on the first packet, the total APDU length) and
+// writes it to dev in hidReportSize packets.
+func writeAPDU(dev hidDevice, apdu []byte) error {
+	var seq uint16
+	for offset := 0; offset == 0 || offset < len(apdu); {
+		packet := make([]byte, hidReportSize)
+		binary.BigEndian.PutUint16(packet[0:], hidChannelID)
+		packet[2] = hidTagAPDU
+		binary.BigEndian.PutUint16(packet[3:], seq)
+		header := 5
+		if seq == 0 {
+			binary.BigEndian.PutUint16(packet[5:], uint16(len(apdu)))
+			header = 7
+		}
+		n := copy(packet[header:], apdu[offset:])
+		if _, err := dev.Write(packet); err != nil {
+			return err
+		}
+		offset += n
+		seq++
+	}
+	return nil
+}
+
+// readAPDU reassembles an APDU response from one or more hidReportSize
+// packets written by the device.
+func readAPDU(dev hidDevice) ([]byte, error) {
+	var (
+		data []byte
+		want int
+		seq  uint16
+	)
+	for {
+		packet := make([]byte, hidReportSize)
+		if _, err := dev.Read(packet); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(packet[3:]) != seq {
+			return nil, ErrDeviceResponse
+		}
+		header := 5
+		if seq == 0 {
+			want = int(binary.BigEndian.Uint16(packet[5:]))
+			header = 7
+		}
+		data = append(data, packet[header:]...)
+		seq++
+		if len(data) >= want {
+			return data[:want], nil
+		}
+	}
+}
+
+// writeAPDUChunked streams a large payload (e.g. an RLP-encoded transaction)
+// to the device as a sequence of APDU commands, each carrying up to
+// apduChunkSize bytes, with p1First marking the first chunk and p1Next every
+// subsequent one.
+func writeAPDUChunked(dev hidDevice, cla, ins byte, p2 byte, p1First, p1Next byte, payload []byte) ([]byte, error) {
+	var resp []byte
+	for offset := 0; offset == 0 || offset < len(payload); {
+		end := offset + apduChunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		p1 := p1Next
+		if offset == 0 {
+			p1 = p1First
+		}
+		r, err := exchangeAPDU(dev, apduCommand{CLA: cla, INS: ins, P1: p1, P2: p2, Data: payload[offset:end]})
+		if err != nil {
+			return nil, err
+		}
+		resp = r
+		offset = end
+		if offset == 0 {
+			break
+		}
+	}
+	return resp, nil
+}
+
+// decodeRecoverableSignature parses the 65-byte (v, r, s) signature returned
+// by both the Ledger and Trezor Ethereum apps, where v is the first byte and
+// r, s follow as 32 bytes each; types.Signature stores r, s, v.
+func decodeRecoverableSignature(resp []byte) (types.Signature, error) {
+	if len(resp) != types.SignatureLength {
+		return types.Signature{}, ErrDeviceResponse
+	}
+	var sig types.Signature
+	copy(sig[:64], resp[1:65])
+	sig[64] = resp[0]
+	return sig, nil
+}
@@ -0,0 +1,288 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/defiweb/go-eth/types"
+)
+
+// mockHidDevice is an hidDevice backed by canned response packets, used to
+// drive LedgerKey/TrezorKey without a real USB device. Writes are recorded
+// for assertions; reads are served from toRead in order.
+type mockHidDevice struct {
+	toRead  [][]byte
+	written [][]byte
+	closed  bool
+}
+
+func (m *mockHidDevice) Write(b []byte) (int, error) {
+	cp := append([]byte{}, b...)
+	m.written = append(m.written, cp)
+	return len(b), nil
+}
+
+func (m *mockHidDevice) Read(b []byte) (int, error) {
+	if len(m.toRead) == 0 {
+		return 0, errors.New("mockHidDevice: no more packets queued")
+	}
+	packet := m.toRead[0]
+	m.toRead = m.toRead[1:]
+	return copy(b, packet), nil
+}
+
+func (m *mockHidDevice) Close() error {
+	m.closed = true
+	return nil
+}
+
+// ledgerResponsePackets frames data (an APDU response payload plus its
+// trailing 2-byte status word) the way a Ledger device writes it back,
+// matching the framing readAPDU expects.
+func ledgerResponsePackets(data []byte) [][]byte {
+	var packets [][]byte
+	var seq uint16
+	for offset := 0; offset == 0 || offset < len(data); {
+		packet := make([]byte, hidReportSize)
+		binary.BigEndian.PutUint16(packet[0:], hidChannelID)
+		packet[2] = hidTagAPDU
+		binary.BigEndian.PutUint16(packet[3:], seq)
+		header := 5
+		if seq == 0 {
+			binary.BigEndian.PutUint16(packet[5:], uint16(len(data)))
+			header = 7
+		}
+		n := copy(packet[header:], data[offset:])
+		offset += n
+		seq++
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func TestLedgerKey_GetAddress(t *testing.T) {
+	addr := types.Address{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	addrHex := []byte(hex.EncodeToString(addr.Bytes()))
+	pub := bytes.Repeat([]byte{0xAB}, 65)
+	var resp []byte
+	resp = append(resp, byte(len(pub)))
+	resp = append(resp, pub...)
+	resp = append(resp, byte(len(addrHex)))
+	resp = append(resp, addrHex...)
+	resp = append(resp, 0x90, 0x00) // status word OK
+
+	dev := &mockHidDevice{toRead: ledgerResponsePackets(resp)}
+	k := &LedgerKey{device: dev, path: []uint32{HardenedOffset + 44, HardenedOffset + 60, HardenedOffset, 0, 0}}
+	got, err := k.getAddress(k.path)
+	if err != nil {
+		t.Fatalf("getAddress: %v", err)
+	}
+	if got != addr {
+		t.Errorf("address = %x, want %x", got, addr)
+	}
+}
+
+func TestLedgerKey_GetAddress_Rejected(t *testing.T) {
+	dev := &mockHidDevice{toRead: ledgerResponsePackets([]byte{0x69, 0x85})} // rejected
+	k := &LedgerKey{device: dev}
+	if _, err := k.getAddress(nil); err != ErrUserRejected {
+		t.Errorf("got %v, want ErrUserRejected", err)
+	}
+}
+
+func TestLedgerKey_SignMessage(t *testing.T) {
+	var resp []byte
+	resp = append(resp, 27) // v
+	resp = append(resp, bytes.Repeat([]byte{0x11}, 32)...)
+	resp = append(resp, bytes.Repeat([]byte{0x22}, 32)...)
+	resp = append(resp, 0x90, 0x00)
+
+	dev := &mockHidDevice{toRead: ledgerResponsePackets(resp)}
+	k := &LedgerKey{device: dev, path: []uint32{HardenedOffset + 44}}
+	sig, err := k.SignMessage([]byte("hello"))
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+	if sig[64] != 27 || !bytes.Equal(sig[0:32], bytes.Repeat([]byte{0x11}, 32)) || !bytes.Equal(sig[32:64], bytes.Repeat([]byte{0x22}, 32)) {
+		t.Errorf("sig = %x, want v=27 r=0x11... s=0x22...", sig)
+	}
+
+	// The path and message should have been sent as a single APDU (it's
+	// short enough to fit in one ledgerINSSignPersonalMsg chunk).
+	if len(dev.written) != 1 {
+		t.Fatalf("wrote %d packets, want 1", len(dev.written))
+	}
+	// req is framed as [channelID(2) tag(1) seq(2) apduLen(2) CLA INS ...];
+	// INS is the second byte of the APDU, after the 7-byte HID header.
+	req := dev.written[0]
+	if req[8] != ledgerINSSignPersonalMsg {
+		t.Errorf("INS = %#x, want %#x", req[8], ledgerINSSignPersonalMsg)
+	}
+}
+
+func TestLedgerKey_SignHash_Unsupported(t *testing.T) {
+	k := &LedgerKey{}
+	if _, err := k.SignHash(types.Hash{}); err != ErrRawHashSigningUnsupported {
+		t.Errorf("got %v, want ErrRawHashSigningUnsupported", err)
+	}
+}
+
+func TestTrezorKey_SignHash_Unsupported(t *testing.T) {
+	k := &TrezorKey{}
+	if _, err := k.SignHash(types.Hash{}); err != ErrRawHashSigningUnsupported {
+		t.Errorf("got %v, want ErrRawHashSigningUnsupported", err)
+	}
+}
+
+// trezorResponsePackets frames a Trezor Wire Protocol message the way a
+// device writes it back, matching the framing readTrezorMessage expects.
+func trezorResponsePackets(msgType uint16, payload []byte) [][]byte {
+	header := []byte{'?', '#', '#', byte(msgType >> 8), byte(msgType), byte(len(payload) >> 24), byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload))}
+	buf := append(header, payload...)
+	var packets [][]byte
+	for offset := 0; offset == 0 || offset < len(buf); {
+		packet := make([]byte, trezorReportSize)
+		if offset == 0 {
+			n := copy(packet, buf)
+			offset += n
+		} else {
+			packet[0] = '?'
+			n := copy(packet[1:], buf[offset:])
+			offset += n
+		}
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func TestPbVarintRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1 << 20, 1 << 40} {
+		encoded := pbVarint(v)
+		got, n := pbReadVarint(encoded)
+		if got != v || n != len(encoded) {
+			t.Errorf("pbVarint(%d) round trip = (%d, %d), want (%d, %d)", v, got, n, v, len(encoded))
+		}
+	}
+}
+
+func TestPbReadFields(t *testing.T) {
+	msg := append(pbUint32Field(1, 42), pbBytesField(2, []byte("hi"))...)
+	fields := pbReadFields(msg)
+	if v, _ := pbReadVarint(fields[1]); v != 42 {
+		t.Errorf("field 1 = %d, want 42", v)
+	}
+	if string(fields[2]) != "hi" {
+		t.Errorf("field 2 = %q, want %q", fields[2], "hi")
+	}
+}
+
+func TestTrezorKey_GetAddress(t *testing.T) {
+	addr := types.Address{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	resp := pbStringField(2, "0x"+hex.EncodeToString(addr.Bytes()))
+	dev := &mockHidDevice{toRead: trezorResponsePackets(trezorMsgEthereumAddr, resp)}
+	k := &TrezorKey{device: dev}
+	got, err := k.getAddress(nil)
+	if err != nil {
+		t.Fatalf("getAddress: %v", err)
+	}
+	if got != addr {
+		t.Errorf("address = %x, want %x", got, addr)
+	}
+}
+
+func TestTrezorKey_Call_ButtonRequestThenResponse(t *testing.T) {
+	buttonReq := trezorResponsePackets(trezorMsgButtonRequest, nil)
+	final := trezorResponsePackets(trezorMsgEthereumAddr, []byte("ok"))
+	dev := &mockHidDevice{toRead: append(buttonReq, final...)}
+	k := &TrezorKey{device: dev}
+	resp, err := k.call(trezorMsgEthereumGetAddr, nil)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if string(resp) != "ok" {
+		t.Errorf("resp = %q, want %q", resp, "ok")
+	}
+	// The ButtonAck must have been written in response to the ButtonRequest,
+	// in addition to the original request.
+	if len(dev.written) != 2 {
+		t.Fatalf("wrote %d messages, want 2 (request + button ack)", len(dev.written))
+	}
+}
+
+func TestTrezorKey_Call_Failure(t *testing.T) {
+	dev := &mockHidDevice{toRead: trezorResponsePackets(trezorMsgFailure, nil)}
+	k := &TrezorKey{device: dev}
+	if _, err := k.call(trezorMsgEthereumGetAddr, nil); err != ErrUserRejected {
+		t.Errorf("got %v, want ErrUserRejected", err)
+	}
+}
+
+func TestTrezorKey_SignTransaction_RejectsNonLegacy(t *testing.T) {
+	k := &TrezorKey{}
+	tx := &types.Transaction{ChainID: big.NewInt(1), Type: 2}
+	if err := k.SignTransaction(tx); err != ErrUnsupportedTransactionType {
+		t.Errorf("got %v, want ErrUnsupportedTransactionType", err)
+	}
+}
+
+func TestTrezorKey_SignTransaction_DataLengthField(t *testing.T) {
+	data := []byte("some calldata")
+	var resp []byte
+	resp = append(resp, pbUint32Field(1, 0)...)                          // remaining
+	resp = append(resp, pbUint32Field(2, 27)...)                         // v
+	resp = append(resp, pbBytesField(3, bytes.Repeat([]byte{1}, 32))...) // r
+	resp = append(resp, pbBytesField(4, bytes.Repeat([]byte{2}, 32))...) // s
+	dev := &mockHidDevice{toRead: trezorResponsePackets(trezorMsgEthereumTxReq, resp)}
+
+	addr := types.Address{}
+	k := &TrezorKey{device: dev, address: addr}
+	tx := &types.Transaction{
+		ChainID:  big.NewInt(1),
+		Nonce:    big.NewInt(0),
+		GasPrice: big.NewInt(1),
+		GasLimit: 21000,
+		To:       &addr,
+		Value:    big.NewInt(0),
+		Data:     data,
+	}
+	if err := k.SignTransaction(tx); err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+
+	if len(dev.written) == 0 {
+		t.Fatal("nothing was written to the device")
+	}
+	sent := reassembleTrezorPackets(t, dev.written)
+	fields := pbReadFields(sent)
+	gotLen, _ := pbReadVarint(fields[8])
+	if int(gotLen) != len(data) {
+		t.Errorf("data_length field = %d, want %d", gotLen, len(data))
+	}
+}
+
+// reassembleTrezorPackets reverses writeTrezorMessage's framing, returning
+// just the message payload (header stripped), the same way
+// readTrezorMessage does for an incoming message.
+func reassembleTrezorPackets(t *testing.T, packets [][]byte) []byte {
+	t.Helper()
+	first := packets[0]
+	if first[0] != '?' || first[1] != '#' || first[2] != '#' {
+		t.Fatalf("first packet missing \"?##\" marker: %x", first[:9])
+	}
+	want := int(first[5])<<24 | int(first[6])<<16 | int(first[7])<<8 | int(first[8])
+	data := append([]byte{}, first[9:]...)
+	for _, p := range packets[1:] {
+		if len(data) >= want {
+			break
+		}
+		data = append(data, p[1:]...)
+	}
+	if len(data) < want {
+		t.Fatalf("reassembled %d bytes, want %d", len(data), want)
+	}
+	return data[:want]
+}
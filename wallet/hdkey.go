@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"golang.org/x/crypto/ripemd160" //nolint:staticcheck // required for BIP-32 fingerprints
+)
+
+var (
+	ErrInvalidSeedLength = errors.New("wallet: seed must be between 16 and 64 bytes")
+	ErrInvalidPath       = errors.New("wallet: invalid derivation path")
+	ErrDerivedKeyZero    = errors.New("wallet: derived key is zero, derive a different index")
+)
+
+// masterKeySeed is the HMAC-SHA512 key used to derive the BIP-32 master key,
+// as defined by the specification.
+var masterKeySeed = []byte("Bitcoin seed")
+
+// ExtendedKey is a BIP-32 extended private key: a secp256k1 private key
+// together with the chain code and metadata needed to derive child keys.
+type ExtendedKey struct {
+	Key               [32]byte
+	ChainCode         [32]byte
+	Depth             uint8
+	ChildNumber       uint32
+	ParentFingerprint [4]byte
+}
+
+// NewMasterKey derives the BIP-32 master extended key from a BIP-39 seed.
+// The seed must be between 16 and 64 bytes, as produced by MnemonicToSeed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrInvalidSeedLength
+	}
+	mac := hmac.New(sha512.New, masterKeySeed)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := &ExtendedKey{}
+	copy(k.Key[:], sum[:32])
+	copy(k.ChainCode[:], sum[32:])
+	return k, nil
+}
+
+// Derive derives a descendant extended key from a path in the standard
+// "m/44'/60'/0'/0/0" notation, where a trailing apostrophe marks a hardened
+// index.
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indices, err := parseBIP32Path(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := k
+	for _, index := range indices {
+		cur, err = cur.DeriveChild(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+// DeriveChild derives a single child key at the given index. An index
+// greater than or equal to HardenedOffset produces a hardened child.
+func (k *ExtendedKey) DeriveChild(index uint32) (*ExtendedKey, error) {
+	var data []byte
+	if index >= HardenedOffset {
+		data = make([]byte, 0, 37)
+		data = append(data, 0x00)
+		data = append(data, k.Key[:]...)
+	} else {
+		data = k.publicKeyCompressed()
+	}
+	data = append(data, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+
+	mac := hmac.New(sha512.New, k.ChainCode[:])
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(s256.N) >= 0 {
+		return nil, ErrDerivedKeyZero
+	}
+	childKey := new(big.Int).Add(il, new(big.Int).SetBytes(k.Key[:]))
+	childKey.Mod(childKey, s256.N)
+	if childKey.Sign() == 0 {
+		return nil, ErrDerivedKeyZero
+	}
+
+	child := &ExtendedKey{
+		Depth:             k.Depth + 1,
+		ChildNumber:       index,
+		ParentFingerprint: k.fingerprint(),
+	}
+	copy(child.ChainCode[:], sum[32:])
+	childKey.FillBytes(child.Key[:])
+	return child, nil
+}
+
+// publicKeyCompressed returns the SEC1 compressed public key (serP(K))
+// corresponding to the extended key.
+func (k *ExtendedKey) publicKeyCompressed() []byte {
+	_, pub := btcec.PrivKeyFromBytes(s256, k.Key[:])
+	return pub.SerializeCompressed()
+}
+
+// fingerprint returns the first four bytes of RIPEMD160(SHA256(pubkey)), the
+// BIP-32 key identifier used as a child's parent fingerprint.
+func (k *ExtendedKey) fingerprint() [4]byte {
+	sha := sha256.Sum256(k.publicKeyCompressed())
+	h := ripemd160.New()
+	h.Write(sha[:])
+	sum := h.Sum(nil)
+	var fp [4]byte
+	copy(fp[:], sum[:4])
+	return fp
+}
+
+// PrivateKey returns the PrivateKey corresponding to this extended key.
+func (k *ExtendedKey) PrivateKey() *PrivateKey {
+	return NewKeyFromBytes(k.Key[:])
+}
@@ -0,0 +1,178 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"math/big"
+	"testing"
+)
+
+// bip32Vector1Seed is the seed from BIP-32's "Test vector 1".
+var bip32Vector1Seed = mustHex("000102030405060708090a0b0c0d0e0f")
+
+func mustHex(s string) []byte {
+	b := make([]byte, len(s)/2)
+	for i := range b {
+		var v int
+		for j := 0; j < 2; j++ {
+			c := s[2*i+j]
+			v <<= 4
+			switch {
+			case c >= '0' && c <= '9':
+				v |= int(c - '0')
+			case c >= 'a' && c <= 'f':
+				v |= int(c-'a') + 10
+			}
+		}
+		b[i] = byte(v)
+	}
+	return b
+}
+
+func TestNewMasterKey_SeedLength(t *testing.T) {
+	if _, err := NewMasterKey(make([]byte, 15)); err != ErrInvalidSeedLength {
+		t.Fatalf("want ErrInvalidSeedLength for a 15-byte seed, got %v", err)
+	}
+	if _, err := NewMasterKey(make([]byte, 65)); err != ErrInvalidSeedLength {
+		t.Fatalf("want ErrInvalidSeedLength for a 65-byte seed, got %v", err)
+	}
+	if _, err := NewMasterKey(make([]byte, 16)); err != nil {
+		t.Fatalf("16-byte seed should be accepted: %v", err)
+	}
+	if _, err := NewMasterKey(make([]byte, 64)); err != nil {
+		t.Fatalf("64-byte seed should be accepted: %v", err)
+	}
+}
+
+// TestNewMasterKey_Vector1 checks the master key derived from BIP-32 test
+// vector 1's seed against an independent computation of
+// HMAC-SHA512("Bitcoin seed", seed).
+func TestNewMasterKey_Vector1(t *testing.T) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(bip32Vector1Seed)
+	sum := mac.Sum(nil)
+
+	master, err := NewMasterKey(bip32Vector1Seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey: %v", err)
+	}
+	if !bytes.Equal(master.Key[:], sum[:32]) {
+		t.Errorf("master key = %x, want %x", master.Key, sum[:32])
+	}
+	if !bytes.Equal(master.ChainCode[:], sum[32:]) {
+		t.Errorf("master chain code = %x, want %x", master.ChainCode, sum[32:])
+	}
+	if master.Depth != 0 || master.ChildNumber != 0 || master.ParentFingerprint != ([4]byte{}) {
+		t.Errorf("master key metadata should be zero, got depth=%d childNumber=%d parentFingerprint=%x",
+			master.Depth, master.ChildNumber, master.ParentFingerprint)
+	}
+}
+
+// TestExtendedKey_DeriveChild_Hardened derives the BIP-32 test vector 1
+// hardened child m/0' and checks the result against an independent
+// computation of the BIP-32 hardened derivation formula:
+//
+//	IL, IR = HMAC-SHA512(chainCode, 0x00 || privKey || index)
+//	childKey = (IL + privKey) mod n
+//
+// which exercises everything DeriveChild does except the elliptic-curve
+// point multiplication used for non-hardened children (see
+// TestExtendedKey_DeriveChild_NonHardened).
+func TestExtendedKey_DeriveChild_Hardened(t *testing.T) {
+	master, err := NewMasterKey(bip32Vector1Seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey: %v", err)
+	}
+
+	index := HardenedOffset // m/0'
+	data := make([]byte, 0, 37)
+	data = append(data, 0x00)
+	data = append(data, master.Key[:]...)
+	data = append(data, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+
+	mac := hmac.New(sha512.New, master.ChainCode[:])
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	want := new(big.Int).Add(il, new(big.Int).SetBytes(master.Key[:]))
+	want.Mod(want, s256.N)
+
+	child, err := master.DeriveChild(index)
+	if err != nil {
+		t.Fatalf("DeriveChild: %v", err)
+	}
+	if got := new(big.Int).SetBytes(child.Key[:]); got.Cmp(want) != 0 {
+		t.Errorf("child key = %x, want %x", child.Key, want.FillBytes(make([]byte, 32)))
+	}
+	if !bytes.Equal(child.ChainCode[:], sum[32:]) {
+		t.Errorf("child chain code = %x, want %x", child.ChainCode, sum[32:])
+	}
+	if child.Depth != 1 {
+		t.Errorf("child depth = %d, want 1", child.Depth)
+	}
+	if child.ChildNumber != index {
+		t.Errorf("child number = %d, want %d", child.ChildNumber, index)
+	}
+	if child.ParentFingerprint != master.fingerprint() {
+		t.Errorf("child parent fingerprint = %x, want %x", child.ParentFingerprint, master.fingerprint())
+	}
+}
+
+// TestExtendedKey_DeriveChild_NonHardened exercises the non-hardened path,
+// which depends on secp256k1 point multiplication (to derive the parent's
+// public key) rather than just HMAC and modular arithmetic. It checks
+// determinism and index-sensitivity rather than a golden value.
+func TestExtendedKey_DeriveChild_NonHardened(t *testing.T) {
+	master, err := NewMasterKey(bip32Vector1Seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey: %v", err)
+	}
+	a, err := master.DeriveChild(0)
+	if err != nil {
+		t.Fatalf("DeriveChild(0): %v", err)
+	}
+	again, err := master.DeriveChild(0)
+	if err != nil {
+		t.Fatalf("DeriveChild(0) again: %v", err)
+	}
+	if !bytes.Equal(a.Key[:], again.Key[:]) || !bytes.Equal(a.ChainCode[:], again.ChainCode[:]) {
+		t.Error("deriving the same index twice should be deterministic")
+	}
+	b, err := master.DeriveChild(1)
+	if err != nil {
+		t.Fatalf("DeriveChild(1): %v", err)
+	}
+	if bytes.Equal(a.Key[:], b.Key[:]) {
+		t.Error("deriving different indices should not produce the same key")
+	}
+}
+
+// TestExtendedKey_Derive_Path checks that Derive("m/0'/1") matches the
+// equivalent sequence of DeriveChild calls.
+func TestExtendedKey_Derive_Path(t *testing.T) {
+	master, err := NewMasterKey(bip32Vector1Seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey: %v", err)
+	}
+	want, err := master.DeriveChild(HardenedOffset)
+	if err != nil {
+		t.Fatalf("DeriveChild: %v", err)
+	}
+	want, err = want.DeriveChild(1)
+	if err != nil {
+		t.Fatalf("DeriveChild: %v", err)
+	}
+
+	got, err := master.Derive("m/0'/1")
+	if err != nil {
+		t.Fatalf("Derive: %v", err)
+	}
+	if !bytes.Equal(got.Key[:], want.Key[:]) || !bytes.Equal(got.ChainCode[:], want.ChainCode[:]) {
+		t.Errorf("Derive(\"m/0'/1\") = %x, want %x", got.Key, want.Key)
+	}
+	if got.Depth != 2 {
+		t.Errorf("depth = %d, want 2", got.Depth)
+	}
+}
@@ -0,0 +1,326 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/defiweb/go-eth/crypto"
+)
+
+var (
+	ErrInvalidKeystoreVersion = errors.New("wallet: unsupported keystore version")
+	ErrInvalidKeystoreCipher  = errors.New("wallet: unsupported keystore cipher")
+	ErrInvalidKeystoreKDF     = errors.New("wallet: unsupported keystore KDF")
+	ErrInvalidPassphrase      = errors.New("wallet: invalid passphrase")
+)
+
+// cipherParamsJSON holds the IV used by the symmetric cipher.
+type cipherParamsJSON struct {
+	IV string `json:"iv"`
+}
+
+// kdfParamsJSON holds the parameters of the key derivation function used to
+// turn a passphrase into the key that encrypts the private key.
+type kdfParamsJSON struct {
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n,omitempty"`
+	R     int    `json:"r,omitempty"`
+	P     int    `json:"p,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+}
+
+// cryptoJSON is the "crypto" section of a keystore file.
+type cryptoJSON struct {
+	Cipher       string           `json:"cipher"`
+	CipherText   string           `json:"ciphertext"`
+	CipherParams cipherParamsJSON `json:"cipherparams"`
+	KDF          string           `json:"kdf"`
+	KDFParams    kdfParamsJSON    `json:"kdfparams"`
+	MAC          string           `json:"mac"`
+}
+
+// encryptedKeyJSONV3 is the V3 (current) Web3 Secret Storage keystore format.
+type encryptedKeyJSONV3 struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+// encryptedKeyJSONV1 is the legacy V1 keystore format used by early
+// Geth/Parity releases.
+type encryptedKeyJSONV1 struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"Crypto"`
+	ID      string     `json:"id"`
+	Version string     `json:"version"`
+}
+
+// encryptV3Key encrypts priv with passphrase using scrypt and AES-128-CTR,
+// producing the V3 keystore representation returned by (*PrivateKey).JSON.
+func encryptV3Key(priv *ecdsa.PrivateKey, passphrase string, scryptN, scryptP int) (*encryptedKeyJSONV3, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, 8, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(derivedKey)
+
+	keyBytes := NewKeyFromECDSA(priv).private.D.Bytes()
+	keyBytes = leftPad(keyBytes, 32)
+	iv, cipherText, err := aesCTREncrypt(derivedKey[:16], keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	address := NewKeyFromECDSA(priv).Address()
+	return &encryptedKeyJSONV3{
+		Address: hex.EncodeToString(address.Bytes()),
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: kdfParamsJSON{
+				DKLen: 32,
+				Salt:  hex.EncodeToString(salt),
+				N:     scryptN,
+				R:     8,
+				P:     scryptP,
+			},
+			MAC: hex.EncodeToString(mac.Bytes()),
+		},
+		ID:      newUUID(),
+		Version: 3,
+	}, nil
+}
+
+// DecryptV3JSON decrypts a V3 keystore produced by (*PrivateKey).JSON.
+func DecryptV3JSON(data []byte, passphrase string) (*PrivateKey, error) {
+	var key encryptedKeyJSONV3
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	if key.Version != 3 {
+		return nil, ErrInvalidKeystoreVersion
+	}
+	return decryptKey(key.Crypto, passphrase)
+}
+
+// LoadKeyFile reads and decrypts a V3 keystore file from disk.
+func LoadKeyFile(path, passphrase string) (*PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptV3JSON(data, passphrase)
+}
+
+// SaveKeyFile encrypts the key with passphrase and writes it to path as a V3
+// keystore file.
+func (k *PrivateKey) SaveKeyFile(path, passphrase string, scryptN, scryptP int) error {
+	data, err := k.JSON(passphrase, scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// ChangePassphrase decrypts oldJSON using oldPass, verifies it matches k, and
+// returns a new V3 keystore for k encrypted with newPass.
+func (k *PrivateKey) ChangePassphrase(oldJSON []byte, oldPass, newPass string, scryptN, scryptP int) ([]byte, error) {
+	old, err := DecryptV3JSON(oldJSON, oldPass)
+	if err != nil {
+		return nil, err
+	}
+	if old.Address() != k.Address() {
+		return nil, ErrInvalidSender
+	}
+	return k.JSON(newPass, scryptN, scryptP)
+}
+
+// DecryptV1JSON decrypts a legacy V1 keystore, as produced by early
+// Geth/Parity releases, using PBKDF2-HMAC-SHA256 for key derivation.
+func DecryptV1JSON(data []byte, passphrase string) (*PrivateKey, error) {
+	var key encryptedKeyJSONV1
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	if key.Version != "1" {
+		return nil, ErrInvalidKeystoreVersion
+	}
+	return decryptKey(key.Crypto, passphrase)
+}
+
+// decryptKey decrypts the crypto section of either a V1 or V3 keystore. V3
+// keystores always use aes-128-ctr; legacy V1 keystores, as produced by
+// early Geth/Parity releases, use aes-128-cbc with PKCS7 padding instead.
+func decryptKey(c cryptoJSON, passphrase string) (*PrivateKey, error) {
+	if c.Cipher != "aes-128-ctr" && c.Cipher != "aes-128-cbc" {
+		return nil, ErrInvalidKeystoreCipher
+	}
+	// The MAC and cipher keys below are fixed 16-byte slices of derivedKey;
+	// a keystore file with a short kdfparams.dklen (attacker-controlled,
+	// since this parses untrusted JSON) would otherwise make derivedKey too
+	// short and panic on the slice below instead of failing cleanly.
+	if c.KDFParams.DKLen < 32 {
+		return nil, ErrInvalidKeystoreKDF
+	}
+	salt, err := hex.DecodeString(c.KDFParams.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var derivedKey []byte
+	switch c.KDF {
+	case "scrypt":
+		derivedKey, err = scrypt.Key([]byte(passphrase), salt, c.KDFParams.N, c.KDFParams.R, c.KDFParams.P, c.KDFParams.DKLen)
+	case "pbkdf2":
+		derivedKey = pbkdf2.Key([]byte(passphrase), salt, c.KDFParams.C, c.KDFParams.DKLen, sha256.New)
+	default:
+		return nil, ErrInvalidKeystoreKDF
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer zero(derivedKey)
+
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	wantMAC, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, err
+	}
+	gotMAC := crypto.Keccak256(derivedKey[16:32], cipherText)
+	if subtle.ConstantTimeCompare(gotMAC.Bytes(), wantMAC) != 1 {
+		return nil, ErrInvalidPassphrase
+	}
+
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	var keyBytes []byte
+	switch c.Cipher {
+	case "aes-128-ctr":
+		keyBytes, err = aesCTRDecrypt(derivedKey[:16], iv, cipherText)
+	case "aes-128-cbc":
+		keyBytes, err = aesCBCDecrypt(derivedKey[:16], iv, cipherText)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyFromBytes(keyBytes), nil
+}
+
+func aesCTREncrypt(key, plainText []byte) (iv, cipherText []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	iv = make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+	cipherText = make([]byte, len(plainText))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, plainText)
+	return iv, cipherText, nil
+}
+
+func aesCTRDecrypt(key, iv, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("wallet: invalid IV length %d", len(iv))
+	}
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(plainText, cipherText)
+	return plainText, nil
+}
+
+// aesCBCDecrypt decrypts cipherText with AES-128-CBC and strips its PKCS7
+// padding, as used by legacy V1 Geth/Parity keystores.
+func aesCBCDecrypt(key, iv, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("wallet: invalid IV length %d", len(iv))
+	}
+	if len(cipherText) == 0 || len(cipherText)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("wallet: invalid ciphertext length %d", len(cipherText))
+	}
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plainText, cipherText)
+	return pkcs7Unpad(plainText)
+}
+
+// pkcs7Unpad strips PKCS7 padding from b, validating that the padding is
+// well-formed.
+func pkcs7Unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("wallet: empty plaintext")
+	}
+	padLen := int(b[len(b)-1])
+	if padLen == 0 || padLen > aes.BlockSize || padLen > len(b) {
+		return nil, errors.New("wallet: invalid pkcs7 padding")
+	}
+	for _, p := range b[len(b)-padLen:] {
+		if int(p) != padLen {
+			return nil, errors.New("wallet: invalid pkcs7 padding")
+		}
+	}
+	return b[:len(b)-padLen], nil
+}
+
+// leftPad pads b on the left with zeros until it is size bytes long.
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+// zero overwrites b with zeros so derived key material does not linger in
+// memory after use.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// newUUID returns a random version-4 UUID string for the keystore "id" field.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
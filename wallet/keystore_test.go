@@ -0,0 +1,151 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/defiweb/go-eth/crypto"
+)
+
+func TestDecryptKey_ShortDKLen(t *testing.T) {
+	// A keystore file is untrusted input: a small kdfparams.dklen must be
+	// rejected before it is used to slice the derived key, not panic.
+	c := cryptoJSON{
+		Cipher: "aes-128-ctr",
+		KDF:    "scrypt",
+		KDFParams: kdfParamsJSON{
+			DKLen: 16,
+			Salt:  hex.EncodeToString(make([]byte, 32)),
+			N:     2,
+			R:     8,
+			P:     1,
+		},
+	}
+	if _, err := decryptKey(c, "whatever"); err != ErrInvalidKeystoreKDF {
+		t.Fatalf("got %v, want ErrInvalidKeystoreKDF", err)
+	}
+}
+
+func TestEncryptV3Key_RoundTrip(t *testing.T) {
+	priv := NewRandomKey()
+	data, err := priv.JSON("correct horse", 2, 1)
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	got, err := DecryptV3JSON(data, "correct horse")
+	if err != nil {
+		t.Fatalf("DecryptV3JSON: %v", err)
+	}
+	if got.Address() != priv.Address() {
+		t.Errorf("decrypted address = %x, want %x", got.Address(), priv.Address())
+	}
+
+	if _, err := DecryptV3JSON(data, "wrong password"); err != ErrInvalidPassphrase {
+		t.Errorf("wrong passphrase: got %v, want ErrInvalidPassphrase", err)
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	priv := NewRandomKey()
+	data, err := priv.JSON("old", 2, 1)
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	newData, err := priv.ChangePassphrase(data, "old", "new", 2, 1)
+	if err != nil {
+		t.Fatalf("ChangePassphrase: %v", err)
+	}
+	got, err := DecryptV3JSON(newData, "new")
+	if err != nil {
+		t.Fatalf("DecryptV3JSON with new passphrase: %v", err)
+	}
+	if got.Address() != priv.Address() {
+		t.Errorf("address = %x, want %x", got.Address(), priv.Address())
+	}
+	if _, err := DecryptV3JSON(newData, "old"); err != ErrInvalidPassphrase {
+		t.Errorf("old passphrase after change: got %v, want ErrInvalidPassphrase", err)
+	}
+}
+
+// makeV1Keystore builds a legacy V1 keystore JSON for keyBytes the way early
+// Geth/Parity releases did: PBKDF2-HMAC-SHA256 and AES-128-CBC with PKCS7
+// padding, so DecryptV1JSON can be tested without a real legacy fixture file.
+func makeV1Keystore(t *testing.T, keyBytes []byte, passphrase string) []byte {
+	t.Helper()
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+	const c, dklen = 65536, 32
+	derivedKey := pbkdf2.Key([]byte(passphrase), salt, c, dklen, sha256.New)
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	padded := pkcs7Pad(keyBytes, aes.BlockSize)
+	block, err := aes.NewCipher(derivedKey[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(cipherText, padded)
+	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
+
+	key := encryptedKeyJSONV1{
+		Version: "1",
+		Crypto: cryptoJSON{
+			Cipher:       "aes-128-cbc",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParamsJSON{IV: hex.EncodeToString(iv)},
+			KDF:          "pbkdf2",
+			KDFParams: kdfParamsJSON{
+				DKLen: dklen,
+				Salt:  hex.EncodeToString(salt),
+				C:     c,
+			},
+			MAC: hex.EncodeToString(mac.Bytes()),
+		},
+	}
+	out, err := json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// pkcs7Pad pads b to a multiple of blockSize, the inverse of pkcs7Unpad.
+func pkcs7Pad(b []byte, blockSize int) []byte {
+	padLen := blockSize - len(b)%blockSize
+	out := make([]byte, len(b)+padLen)
+	copy(out, b)
+	for i := len(b); i < len(out); i++ {
+		out[i] = byte(padLen)
+	}
+	return out
+}
+
+func TestDecryptV1JSON_AES128CBC(t *testing.T) {
+	priv := NewRandomKey()
+	keyBytes := leftPad(priv.private.D.Bytes(), 32)
+	data := makeV1Keystore(t, keyBytes, "legacy passphrase")
+
+	got, err := DecryptV1JSON(data, "legacy passphrase")
+	if err != nil {
+		t.Fatalf("DecryptV1JSON: %v", err)
+	}
+	if got.Address() != priv.Address() {
+		t.Errorf("address = %x, want %x", got.Address(), priv.Address())
+	}
+
+	if _, err := DecryptV1JSON(data, "wrong"); err != ErrInvalidPassphrase {
+		t.Errorf("wrong passphrase: got %v, want ErrInvalidPassphrase", err)
+	}
+}
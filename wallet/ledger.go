@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/karalabe/hid"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// ledgerVendorID is the USB vendor ID assigned to Ledger.
+const ledgerVendorID = 0x2c97
+
+// Ledger Ethereum app APDU instructions.
+const (
+	ledgerCLA                = 0xE0
+	ledgerINSGetAddress      = 0x02
+	ledgerINSSignTx          = 0x04
+	ledgerINSSignPersonalMsg = 0x08
+	ledgerP1First            = 0x00
+	ledgerP1Next             = 0x80
+	ledgerP1NoConfirm        = 0x00
+	ledgerP2NoChainCode      = 0x00
+)
+
+// LedgerKey is a Key backed by a connected Ledger hardware wallet running
+// the Ethereum app. It is a drop-in replacement for *PrivateKey in code that
+// only relies on the Key interface, with one exception: SignHash returns
+// ErrRawHashSigningUnsupported (see there for why) instead of a signature.
+type LedgerKey struct {
+	device  hidDevice
+	path    []uint32
+	address types.Address
+}
+
+// OpenLedger opens the Ledger device at path (as reported by
+// github.com/karalabe/hid.Enumerate) and reads the Ethereum address for the
+// given BIP-32 derivation path, e.g. "m/44'/60'/0'/0/0".
+func OpenLedger(path string) (*LedgerKey, error) {
+	indices, err := parseBIP32Path(path)
+	if err != nil {
+		return nil, err
+	}
+	var info *hid.DeviceInfo
+	for _, d := range hid.Enumerate(ledgerVendorID, 0) {
+		if d.Path == path {
+			info = &d
+			break
+		}
+	}
+	if info == nil {
+		return nil, ErrDeviceNotFound
+	}
+	dev, err := info.Open()
+	if err != nil {
+		return nil, err
+	}
+	k := &LedgerKey{device: dev, path: indices}
+	addr, err := k.getAddress(indices)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	k.address = addr
+	return k, nil
+}
+
+// Address implements the Key interface.
+func (k *LedgerKey) Address() types.Address {
+	return k.address
+}
+
+// Close releases the underlying USB HID device.
+func (k *LedgerKey) Close() error {
+	return k.device.Close()
+}
+
+// getAddress sends GET_ADDRESS for the given path and parses the returned
+// Ethereum address.
+func (k *LedgerKey) getAddress(path []uint32) (types.Address, error) {
+	resp, err := exchangeAPDU(k.device, apduCommand{
+		CLA:  ledgerCLA,
+		INS:  ledgerINSGetAddress,
+		P1:   ledgerP1NoConfirm,
+		P2:   ledgerP2NoChainCode,
+		Data: encodeLedgerPath(path),
+	})
+	if err != nil {
+		return types.Address{}, err
+	}
+	// Response: 1-byte public key length, public key, 1-byte address string
+	// length, address as an ASCII hex string.
+	if len(resp) < 1 {
+		return types.Address{}, ErrDeviceResponse
+	}
+	pubLen := int(resp[0])
+	if len(resp) < 1+pubLen+1 {
+		return types.Address{}, ErrDeviceResponse
+	}
+	addrLen := int(resp[1+pubLen])
+	if len(resp) < 1+pubLen+1+addrLen {
+		return types.Address{}, ErrDeviceResponse
+	}
+	addrBytes, err := hex.DecodeString(string(resp[1+pubLen+1 : 1+pubLen+1+addrLen]))
+	if err != nil {
+		return types.Address{}, fmt.Errorf("wallet: invalid address from device: %w", err)
+	}
+	if len(addrBytes) != types.AddressLength {
+		return types.Address{}, ErrDeviceResponse
+	}
+	var addr types.Address
+	copy(addr[:], addrBytes)
+	return addr, nil
+}
+
+// SignHash implements the Key interface, except it always fails: unlike
+// PrivateKey.SignHash, which signs hash directly, the Ledger Ethereum app has
+// no instruction to sign an arbitrary digest blind. Wrapping hash as a
+// personal message and signing that instead, as an earlier version of this
+// method did, produces a signature over a different message than the one
+// requested — silently incompatible with PrivateKey.SignHash and anything
+// that verifies against the original hash (a precomputed transaction hash or
+// EIP-712 digest, for example). See ErrRawHashSigningUnsupported.
+func (k *LedgerKey) SignHash(hash types.Hash) (types.Signature, error) {
+	return types.Signature{}, ErrRawHashSigningUnsupported
+}
+
+// SignMessage implements the Key interface.
+func (k *LedgerKey) SignMessage(data []byte) (types.Signature, error) {
+	return k.signPersonalMessage(data)
+}
+
+func (k *LedgerKey) signPersonalMessage(data []byte) (types.Signature, error) {
+	payload := append(encodeLedgerPath(k.path), data...)
+	resp, err := writeAPDUChunked(k.device, ledgerCLA, ledgerINSSignPersonalMsg, 0,
+		ledgerP1First, ledgerP1Next, payload)
+	if err != nil {
+		return types.Signature{}, err
+	}
+	return decodeRecoverableSignature(resp)
+}
+
+// SignTransaction implements the Key interface. tx is RLP-encoded and
+// streamed to the device in apduChunkSize-byte APDU chunks; the returned
+// (v, r, s) populate tx.Signature.
+func (k *LedgerKey) SignTransaction(tx *types.Transaction) error {
+	if tx.ChainID == nil {
+		return ErrMissingChainID
+	}
+	rlp, err := tx.EncodeRLP()
+	if err != nil {
+		return err
+	}
+	payload := append(encodeLedgerPath(k.path), rlp...)
+	resp, err := writeAPDUChunked(k.device, ledgerCLA, ledgerINSSignTx, 0,
+		ledgerP1First, ledgerP1Next, payload)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeRecoverableSignature(resp)
+	if err != nil {
+		return err
+	}
+	addr := k.Address()
+	tx.From = &addr
+	tx.Signature = &sig
+	return nil
+}
+
+// VerifyHash implements the Key interface, recovering against hash directly,
+// the same as PrivateKey.VerifyHash — it verifies a signature against a raw
+// digest regardless of which Key produced it, so it does not mirror
+// SignHash's refusal.
+func (k *LedgerKey) VerifyHash(hash types.Hash, sig types.Signature) bool {
+	addr, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return false
+	}
+	return addr == k.address
+}
+
+// VerifyMessage implements the Key interface.
+func (k *LedgerKey) VerifyMessage(data []byte, sig types.Signature) bool {
+	addr, err := crypto.EcrecoverMessage(data, sig)
+	if err != nil {
+		return false
+	}
+	return addr == k.address
+}
+
+// encodeLedgerPath serializes a derivation path as a 1-byte component count
+// followed by each index as a big-endian uint32, as expected by the Ledger
+// Ethereum app.
+func encodeLedgerPath(path []uint32) []byte {
+	out := make([]byte, 1+4*len(path))
+	out[0] = byte(len(path))
+	for i, idx := range path {
+		out[1+4*i] = byte(idx >> 24)
+		out[1+4*i+1] = byte(idx >> 16)
+		out[1+4*i+2] = byte(idx >> 8)
+		out[1+4*i+3] = byte(idx)
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// HardenedOffset is added to a child index to mark it as hardened, as
+// defined by BIP-44 (indices written with a trailing apostrophe, e.g. 44').
+const HardenedOffset = uint32(0x80000000)
+
+var (
+	ErrInvalidEntropySize    = errors.New("wallet: entropy size must be 128, 160, 192, 224 or 256 bits")
+	ErrInvalidMnemonicLength = errors.New("wallet: mnemonic must have 12, 15, 18, 21 or 24 words")
+	ErrUnknownMnemonicWord   = errors.New("wallet: mnemonic contains a word that is not in the wordlist")
+	ErrInvalidChecksum       = errors.New("wallet: mnemonic checksum does not match")
+)
+
+// Mnemonic is a BIP-39 mnemonic phrase.
+type Mnemonic struct {
+	words []string
+}
+
+// NewMnemonic generates a new random mnemonic from entropyBits bits of
+// entropy. entropyBits must be one of 128, 160, 192, 224 or 256, producing a
+// 12, 15, 18, 21 or 24 word phrase respectively.
+func NewMnemonic(entropyBits int) (*Mnemonic, error) {
+	if entropyBits < 128 || entropyBits > 256 || entropyBits%32 != 0 {
+		return nil, ErrInvalidEntropySize
+	}
+	entropy := make([]byte, entropyBits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return mnemonicFromEntropy(entropy)
+}
+
+// ParseMnemonic parses and validates an existing mnemonic phrase, verifying
+// that every word belongs to the English wordlist and that the embedded
+// checksum is correct.
+func ParseMnemonic(phrase string) (*Mnemonic, error) {
+	words := strings.Fields(phrase)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, ErrInvalidMnemonicLength
+	}
+	index := make(map[string]int, len(englishWordlist))
+	for i, w := range englishWordlist {
+		index[w] = i
+	}
+	entropyBits := len(words) * 11 * 32 / 33
+	checksumBits := len(words) * 11 / 33
+	bits := make([]byte, 0, len(words)*11)
+	for _, w := range words {
+		i, ok := index[w]
+		if !ok {
+			return nil, ErrUnknownMnemonicWord
+		}
+		for b := 10; b >= 0; b-- {
+			bits = append(bits, byte(i>>b)&1)
+		}
+	}
+	entropy := packBits(bits[:entropyBits])
+	sum := sha256.Sum256(entropy)
+	for i := 0; i < checksumBits; i++ {
+		want := (sum[0] >> (7 - i)) & 1
+		if bits[entropyBits+i] != want {
+			return nil, ErrInvalidChecksum
+		}
+	}
+	return &Mnemonic{words: words}, nil
+}
+
+func mnemonicFromEntropy(entropy []byte) (*Mnemonic, error) {
+	entropyBits := len(entropy) * 8
+	checksumBits := entropyBits / 32
+	sum := sha256.Sum256(entropy)
+
+	bits := make([]byte, 0, entropyBits+checksumBits)
+	for _, b := range entropy {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, (b>>i)&1)
+		}
+	}
+	for i := 0; i < checksumBits; i++ {
+		bits = append(bits, (sum[0]>>(7-i))&1)
+	}
+
+	wordCount := len(bits) / 11
+	words := make([]string, wordCount)
+	for i := 0; i < wordCount; i++ {
+		idx := 0
+		for _, b := range bits[i*11 : i*11+11] {
+			idx = idx<<1 | int(b)
+		}
+		words[i] = englishWordlist[idx]
+	}
+	return &Mnemonic{words: words}, nil
+}
+
+// packBits packs a slice of 0/1 bytes, most significant bit first, into a
+// byte slice. len(bits) must be a multiple of 8.
+func packBits(bits []byte) []byte {
+	out := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b != 0 {
+			out[i/8] |= 1 << (7 - uint(i%8))
+		}
+	}
+	return out
+}
+
+// String returns the space-separated mnemonic phrase.
+func (m *Mnemonic) String() string {
+	return strings.Join(m.words, " ")
+}
+
+// Words returns the individual words of the mnemonic phrase.
+func (m *Mnemonic) Words() []string {
+	return m.words
+}
+
+// Seed derives the 64-byte BIP-39 seed from the mnemonic and an optional
+// passphrase.
+func (m *Mnemonic) Seed(passphrase string) []byte {
+	return MnemonicToSeed(m.String(), passphrase)
+}
+
+// MnemonicToSeed derives a 64-byte seed from a mnemonic phrase and an
+// optional passphrase using PBKDF2-HMAC-SHA512 with 2048 iterations, as
+// defined by BIP-39.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	salt := fmt.Sprintf("mnemonic%s", passphrase)
+	return pbkdf2.Key([]byte(mnemonic), []byte(salt), 2048, 64, sha512.New)
+}
@@ -0,0 +1,139 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// paillierBits is the bit length of each of the two safe-ish primes used to
+// build a Paillier modulus, chosen so that N is comfortably larger than the
+// secp256k1 scalar field it is used to carry.
+const paillierBits = 1024
+
+var ErrPaillierDecrypt = errors.New("wallet: paillier ciphertext is not decryptable with this key")
+
+// paillierPublicKey is a Paillier public key: N, and its square Nsq, cached
+// for repeated use.
+type paillierPublicKey struct {
+	N   *big.Int
+	Nsq *big.Int
+}
+
+// paillierPrivateKey is a Paillier private key.
+type paillierPrivateKey struct {
+	paillierPublicKey
+	lambda *big.Int
+	mu     *big.Int
+}
+
+// generatePaillierKey generates a fresh Paillier keypair.
+func generatePaillierKey() (*paillierPrivateKey, error) {
+	p, err := rand.Prime(rand.Reader, paillierBits)
+	if err != nil {
+		return nil, err
+	}
+	q, err := rand.Prime(rand.Reader, paillierBits)
+	if err != nil {
+		return nil, err
+	}
+	n := new(big.Int).Mul(p, q)
+	nsq := new(big.Int).Mul(n, n)
+
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+	qMinus1 := new(big.Int).Sub(q, big.NewInt(1))
+	lambda := new(big.Int).Mul(pMinus1, qMinus1)
+	lambda.Div(lambda, new(big.Int).GCD(nil, nil, pMinus1, qMinus1))
+
+	// g = N+1 is a valid generator whenever N is the product of two primes,
+	// which simplifies g^lambda mod N^2 to 1 + lambda*N, so L of it is lambda
+	// mod N and mu is just lambda's inverse mod N.
+	mu := new(big.Int).ModInverse(lambda, n)
+	if mu == nil {
+		return nil, errors.New("wallet: failed to generate paillier key, retry")
+	}
+
+	return &paillierPrivateKey{
+		paillierPublicKey: paillierPublicKey{N: n, Nsq: nsq},
+		lambda:            lambda,
+		mu:                mu,
+	}, nil
+}
+
+// Encrypt encrypts m (0 <= m < N) under pk, returning the ciphertext
+// g^m * r^N mod N^2 for a fresh random r.
+func (pk *paillierPublicKey) Encrypt(m *big.Int) (*big.Int, error) {
+	c, _, err := pk.EncryptRandom(m)
+	return c, err
+}
+
+// EncryptRandom is Encrypt, but also returns the randomness r it used. Most
+// callers only need the ciphertext (that's what Encrypt is for); mtaRespond
+// keeps r so it can prove, via proveMtARange, that the ciphertext it sends
+// was built correctly without revealing m.
+func (pk *paillierPublicKey) EncryptRandom(m *big.Int) (c, r *big.Int, err error) {
+	r, err = rand.Int(rand.Reader, pk.N)
+	if err != nil {
+		return nil, nil, err
+	}
+	for r.Sign() == 0 {
+		if r, err = rand.Int(rand.Reader, pk.N); err != nil {
+			return nil, nil, err
+		}
+	}
+	return pk.encryptWithRandomness(m, r), r, nil
+}
+
+// encryptWithRandomness is Encrypt with an explicit, caller-chosen r instead
+// of a freshly sampled one, as needed to recompute a ciphertext's commitment
+// term inside proveMtARange/mtaRangeProof.verify.
+func (pk *paillierPublicKey) encryptWithRandomness(m, r *big.Int) *big.Int {
+	// g = N+1, so g^m mod N^2 = 1 + m*N mod N^2.
+	gm := new(big.Int).Mul(m, pk.N)
+	gm.Add(gm, big.NewInt(1))
+	gm.Mod(gm, pk.Nsq)
+
+	rn := new(big.Int).Exp(r, pk.N, pk.Nsq)
+	c := new(big.Int).Mul(gm, rn)
+	return c.Mod(c, pk.Nsq)
+}
+
+// Decrypt recovers the plaintext m from ciphertext c.
+func (sk *paillierPrivateKey) Decrypt(c *big.Int) (*big.Int, error) {
+	if c.Sign() < 0 || c.Cmp(sk.Nsq) >= 0 {
+		return nil, ErrPaillierDecrypt
+	}
+	u := new(big.Int).Exp(c, sk.lambda, sk.Nsq)
+	l := paillierL(u, sk.N)
+	m := new(big.Int).Mul(l, sk.mu)
+	return m.Mod(m, sk.N), nil
+}
+
+// paillierL computes L(x) = (x-1)/N, the standard Paillier auxiliary
+// function.
+func paillierL(x, n *big.Int) *big.Int {
+	l := new(big.Int).Sub(x, big.NewInt(1))
+	return l.Div(l, n)
+}
+
+// HomomorphicAdd returns an encryption of (m1+m2) given encryptions c1, c2
+// of m1, m2 under the same key, exploiting Paillier's additive homomorphism:
+// Enc(m1)*Enc(m2) = Enc(m1+m2) mod N^2.
+func (pk *paillierPublicKey) HomomorphicAdd(c1, c2 *big.Int) *big.Int {
+	c := new(big.Int).Mul(c1, c2)
+	return c.Mod(c, pk.Nsq)
+}
+
+// HomomorphicScalarMul returns an encryption of (m*k) given an encryption c
+// of m under the same key, exploiting Enc(m)^k = Enc(m*k) mod N^2. This is
+// the multiplication half of the multiplicative-to-additive (MtA) share
+// conversion used by threshold.go.
+func (pk *paillierPublicKey) HomomorphicScalarMul(c, k *big.Int) *big.Int {
+	return new(big.Int).Exp(c, k, pk.Nsq)
+}
+
+// newPaillierPublicKey reconstructs the public half of a Paillier key from
+// its modulus N alone, as received from a peer.
+func newPaillierPublicKey(n *big.Int) *paillierPublicKey {
+	return &paillierPublicKey{N: n, Nsq: new(big.Int).Mul(n, n)}
+}
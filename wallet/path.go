@@ -0,0 +1,32 @@
+package wallet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseBIP32Path parses a derivation path in the standard "m/44'/60'/0'/0/0"
+// notation into its component indices, with HardenedOffset added to indices
+// marked with a trailing apostrophe.
+func parseBIP32Path(path string) ([]uint32, error) {
+	segments := strings.Split(path, "/")
+	if len(segments) == 0 || segments[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	indices := make([]uint32, 0, len(segments)-1)
+	for _, seg := range segments[1:] {
+		hardened := strings.HasSuffix(seg, "'")
+		seg = strings.TrimSuffix(seg, "'")
+		i, err := strconv.ParseUint(seg, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, seg)
+		}
+		index := uint32(i)
+		if hardened {
+			index += HardenedOffset
+		}
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+var ErrNotEnoughShares = errors.New("wallet: not enough shares to reconstruct secret")
+
+// shamirPolynomial is a polynomial over the secp256k1 scalar field, used to
+// split a secret into Shamir shares. coefficients[0] is the secret itself.
+type shamirPolynomial struct {
+	coefficients []*big.Int
+}
+
+// newShamirPolynomial builds a random degree t-1 polynomial whose constant
+// term is secret.
+func newShamirPolynomial(secret *big.Int, t int) (*shamirPolynomial, error) {
+	coefficients := make([]*big.Int, t)
+	coefficients[0] = new(big.Int).Mod(secret, s256.N)
+	for i := 1; i < t; i++ {
+		c, err := rand.Int(rand.Reader, s256.N)
+		if err != nil {
+			return nil, err
+		}
+		coefficients[i] = c
+	}
+	return &shamirPolynomial{coefficients: coefficients}, nil
+}
+
+// eval evaluates the polynomial at x (mod the curve order).
+func (p *shamirPolynomial) eval(x *big.Int) *big.Int {
+	result := new(big.Int)
+	xPow := big.NewInt(1)
+	for _, c := range p.coefficients {
+		term := new(big.Int).Mul(c, xPow)
+		result.Add(result, term)
+		xPow = new(big.Int).Mul(xPow, x)
+		xPow.Mod(xPow, s256.N)
+	}
+	return result.Mod(result, s256.N)
+}
+
+// commitments returns coefficients[k]*G for every coefficient, the Feldman
+// verifiable-secret-sharing commitments that let a recipient verify a share
+// without learning the polynomial.
+func (p *shamirPolynomial) commitments() [][]byte {
+	out := make([][]byte, len(p.coefficients))
+	for i, c := range p.coefficients {
+		_, pub := btcec.PrivKeyFromBytes(s256, c.FillBytes(make([]byte, 32)))
+		out[i] = pub.SerializeCompressed()
+	}
+	return out
+}
+
+// verifyFeldmanShare checks that share = f(id) is consistent with the
+// broadcast commitments to f's coefficients, i.e. that
+// share*G == sum(commitments[k] * id^k).
+func verifyFeldmanShare(id uint32, share *big.Int, commitments [][]byte) (bool, error) {
+	_, lhs := btcec.PrivKeyFromBytes(s256, share.FillBytes(make([]byte, 32)))
+
+	x := big.NewInt(int64(id))
+	xPow := big.NewInt(1)
+	var rhsX, rhsY *big.Int
+	for _, c := range commitments {
+		pub, err := btcec.ParsePubKey(c, s256)
+		if err != nil {
+			return false, err
+		}
+		px, py := s256.ScalarMult(pub.X, pub.Y, xPow.Bytes())
+		if rhsX == nil {
+			rhsX, rhsY = px, py
+		} else {
+			rhsX, rhsY = s256.Add(rhsX, rhsY, px, py)
+		}
+		xPow = new(big.Int).Mul(xPow, x)
+		xPow.Mod(xPow, s256.N)
+	}
+	return lhs.X.Cmp(rhsX) == 0 && lhs.Y.Cmp(rhsY) == 0, nil
+}
+
+// lagrangeCoefficient computes the Lagrange basis coefficient of id within
+// ids, evaluated at x=0, mod the curve order.
+func lagrangeCoefficient(ids []uint32, id uint32) *big.Int {
+	num := big.NewInt(1)
+	den := big.NewInt(1)
+	for _, other := range ids {
+		if other == id {
+			continue
+		}
+		num.Mul(num, big.NewInt(-int64(other)))
+		num.Mod(num, s256.N)
+		diff := new(big.Int).Sub(big.NewInt(int64(id)), big.NewInt(int64(other)))
+		den.Mul(den, diff.Mod(diff, s256.N))
+		den.Mod(den, s256.N)
+	}
+	denInv := new(big.Int).ModInverse(den, s256.N)
+	return num.Mul(num, denInv).Mod(num, s256.N)
+}
+
+// combineShares reconstructs the secret shared among shares via Lagrange
+// interpolation at x=0.
+func combineShares(shares map[uint32]*big.Int) (*big.Int, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	ids := make([]uint32, 0, len(shares))
+	for id := range shares {
+		ids = append(ids, id)
+	}
+	secret := new(big.Int)
+	for id, share := range shares {
+		term := new(big.Int).Mul(share, lagrangeCoefficient(ids, id))
+		secret.Add(secret, term)
+	}
+	return secret.Mod(secret, s256.N), nil
+}
@@ -0,0 +1,553 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// Transport lets the parties of a ThresholdKey exchange the messages needed
+// for distributed key generation and signing. Implementations are free to
+// wire it to any network layer (gRPC, libp2p, ...); NewInProcessTransports
+// provides an in-memory implementation for tests and single-process use.
+type Transport interface {
+	// ID returns the party ID this Transport instance speaks for.
+	ID() int
+	// Broadcast publishes payload, tagged with round, to every other party.
+	Broadcast(round string, payload []byte) error
+	// RecvBroadcast returns the payload party from broadcast for round.
+	RecvBroadcast(round string, from int) ([]byte, error)
+	// Send delivers payload privately to party to, tagged with round.
+	Send(round string, to int, payload []byte) error
+	// Recv returns the private message sent to this party by from for round.
+	Recv(round string, from int) ([]byte, error)
+}
+
+var (
+	ErrInvalidThreshold   = errors.New("wallet: threshold must satisfy 1 <= t <= n")
+	ErrThresholdMismatch  = errors.New("wallet: not enough parties, or parties from different keys, to sign")
+	ErrShareVerification  = errors.New("wallet: received share failed Feldman VSS verification")
+	ErrSignRecovery       = errors.New("wallet: could not recover a valid signature from the combined shares")
+	ErrTransportNoMessage = errors.New("wallet: no message received for the given round and party")
+)
+
+// ThresholdKey is one party's share of a t-of-n threshold ECDSA key, as
+// produced by GenerateThresholdKeys. No single ThresholdKey holds the full
+// private key; a signature requires t cooperating parties, combined with
+// Sign, and Sign never reconstructs it either.
+type ThresholdKey struct {
+	ID      int
+	T, N    int
+	share   *big.Int
+	address types.Address
+}
+
+// Address is the Ethereum address of the joint public key. It is the same
+// for every party sharing this key.
+func (k *ThresholdKey) Address() types.Address {
+	return k.address
+}
+
+const (
+	roundCommitments = "commitments"
+	roundShares      = "shares"
+)
+
+// GenerateThresholdKeys runs a Feldman-VSS distributed key generation
+// protocol across the n parties behind transports (transports[i].ID() must
+// be unique and in [1, n]), producing one ThresholdKey per party. No party,
+// and no message ever sent over a Transport, reveals the joint private key:
+// each party only ever learns its own Shamir share of it.
+func GenerateThresholdKeys(t, n int, transports []Transport) ([]*ThresholdKey, error) {
+	if t < 1 || t > n || len(transports) != n {
+		return nil, ErrInvalidThreshold
+	}
+
+	polynomials := make([]*shamirPolynomial, n)
+	for i := 0; i < n; i++ {
+		secret, err := randScalar()
+		if err != nil {
+			return nil, err
+		}
+		poly, err := newShamirPolynomial(secret, t)
+		if err != nil {
+			return nil, err
+		}
+		polynomials[i] = poly
+	}
+
+	// Round 1: every party broadcasts Feldman commitments to its polynomial.
+	for i, tr := range transports {
+		if err := tr.Broadcast(roundCommitments, encodeByteList(polynomials[i].commitments())); err != nil {
+			return nil, err
+		}
+	}
+	// commitments[i] holds the commitments broadcast by the party with
+	// index i (i.e. transports[i]), keyed the same way as polynomials. Every
+	// Transport observes the same broadcasts, so it doesn't matter which one
+	// we read them back through.
+	commitments := make([][][]byte, n)
+	for i := range transports {
+		raw, err := transports[0].RecvBroadcast(roundCommitments, transports[i].ID())
+		if err != nil {
+			return nil, err
+		}
+		commitments[i] = decodeByteList(raw)
+	}
+
+	// Round 2: every party privately sends every party, itself included, its
+	// share of its own polynomial.
+	for i, tr := range transports {
+		for _, recipient := range transports {
+			share := polynomials[i].eval(big.NewInt(int64(recipient.ID())))
+			if err := tr.Send(roundShares, recipient.ID(), share.FillBytes(make([]byte, 32))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The joint public key is the sum of every party's constant-term
+	// commitment, since the joint secret is the sum of every party's
+	// polynomial's constant term.
+	var jointX, jointY *big.Int
+	for i := range transports {
+		cx, cy, err := decompressPoint(commitments[i][0])
+		if err != nil {
+			return nil, err
+		}
+		if jointX == nil {
+			jointX, jointY = cx, cy
+		} else {
+			jointX, jointY = s256.Add(jointX, jointY, cx, cy)
+		}
+	}
+	address := pubKeyToAddress(jointX, jointY)
+
+	keys := make([]*ThresholdKey, n)
+	for i, tr := range transports {
+		total := new(big.Int)
+		for j := 0; j < n; j++ {
+			raw, err := tr.Recv(roundShares, transports[j].ID())
+			if err != nil {
+				return nil, err
+			}
+			share := new(big.Int).SetBytes(raw)
+			ok, err := verifyFeldmanShare(uint32(tr.ID()), share, commitments[j])
+			if err != nil || !ok {
+				return nil, ErrShareVerification
+			}
+			total.Add(total, share)
+		}
+		total.Mod(total, s256.N)
+		keys[i] = &ThresholdKey{ID: tr.ID(), T: t, N: n, share: total, address: address}
+	}
+	return keys, nil
+}
+
+// Signing rounds. Each is broadcast except roundMtAResp, which is a private
+// reply from the responder of an MtA exchange back to its initiator.
+const (
+	roundMtAInit = "mta-init"  // broadcast: initiator's Paillier pubkey + Enc(k_i)
+	roundGamma   = "gamma"     // broadcast: gamma_i*G
+	roundMtAResp = "mta-resp"  // private, responder -> initiator: MtA response ciphertexts
+	roundDelta   = "delta"     // broadcast: delta_i = k_i*gamma_i + pairwise MtA terms
+	roundSigma   = "sig-share" // broadcast: s_i = hash*k_i + r*sigma_i
+)
+
+// signParty is the per-party state kept for the lifetime of one Sign call.
+// It never holds, or computes, the joint private key or the joint nonce.
+type signParty struct {
+	tr       Transport
+	w        *big.Int // this party's Lagrange-weighted key share
+	k        *big.Int // this party's additive nonce share
+	gamma    *big.Int // this party's blinding share
+	paillier *paillierPrivateKey
+}
+
+// Sign produces a standard 65-byte signature of hash using the t parties
+// behind keys and transports (paired by index), following the structure of
+// Gennaro-Goldfeder-style threshold ECDSA: a fresh nonce is generated the
+// same additive way as the key share, and every product of two parties'
+// secrets (k_i*gamma_j and k_i*w_j) is converted from multiplicative to
+// additive shares using Paillier encryption, so that the only values any
+// party ever reveals are the public nonce point, the blinded delta, and its
+// own signature share s_i. At no point does any party, or this function,
+// ever hold the joint private key or the joint nonce k in the clear.
+//
+// Every MtA response is accompanied by an mtaRangeProof (see mta_proof.go),
+// which the initiator verifies before decrypting: a malicious co-signer that
+// submits an inconsistent or out-of-range MtA ciphertext is rejected with
+// ErrMtARangeProof instead of silently corrupting delta_i/sigma_i.
+func Sign(hash types.Hash, keys []*ThresholdKey, transports []Transport) (types.Signature, error) {
+	if len(keys) == 0 || len(keys) != len(transports) {
+		return types.Signature{}, ErrThresholdMismatch
+	}
+	t, addr := keys[0].T, keys[0].address
+	if len(keys) < t {
+		return types.Signature{}, ErrThresholdMismatch
+	}
+	keys, transports = keys[:t], transports[:t]
+
+	ids := make([]uint32, len(keys))
+	for i, k := range keys {
+		if k.address != addr || transports[i].ID() != k.ID {
+			return types.Signature{}, ErrThresholdMismatch
+		}
+		ids[i] = uint32(k.ID)
+	}
+
+	parties := make([]*signParty, t)
+	for i, k := range keys {
+		gamma, err := randScalar()
+		if err != nil {
+			return types.Signature{}, err
+		}
+		kShare, err := randScalar()
+		if err != nil {
+			return types.Signature{}, err
+		}
+		paillierKey, err := generatePaillierKey()
+		if err != nil {
+			return types.Signature{}, err
+		}
+		w := new(big.Int).Mul(k.share, lagrangeCoefficient(ids, uint32(k.ID)))
+		w.Mod(w, s256.N)
+		parties[i] = &signParty{tr: transports[i], w: w, k: kShare, gamma: gamma, paillier: paillierKey}
+	}
+
+	// Round 1: every party broadcasts its nonce commitment Gamma_i = gamma_i*G
+	// and a Paillier encryption of its nonce share k_i.
+	for _, p := range parties {
+		if err := p.tr.Broadcast(roundGamma, scalarBaseMultCompressed(p.gamma)); err != nil {
+			return types.Signature{}, err
+		}
+		enc, err := p.paillier.Encrypt(p.k)
+		if err != nil {
+			return types.Signature{}, err
+		}
+		if err := p.tr.Broadcast(roundMtAInit, encodeByteList([][]byte{p.paillier.N.Bytes(), enc.Bytes()})); err != nil {
+			return types.Signature{}, err
+		}
+	}
+
+	gammaPoints := make([][]byte, t)
+	initPaillier := make([]*paillierPublicKey, t)
+	initEncK := make([]*big.Int, t)
+	for i := range parties {
+		raw, err := parties[0].tr.RecvBroadcast(roundGamma, partyID(ids, i))
+		if err != nil {
+			return types.Signature{}, err
+		}
+		gammaPoints[i] = raw
+		raw, err = parties[0].tr.RecvBroadcast(roundMtAInit, partyID(ids, i))
+		if err != nil {
+			return types.Signature{}, err
+		}
+		parts := decodeByteList(raw)
+		initPaillier[i] = newPaillierPublicKey(new(big.Int).SetBytes(parts[0]))
+		initEncK[i] = new(big.Int).SetBytes(parts[1])
+	}
+
+	// Round 2: every party, as MtA responder, replies to every other
+	// party's initiation with blinded multiplicative shares of k_i*gamma_j
+	// and k_i*w_j, keeping its own additive half (beta, nu) locally.
+	beta := make(map[[2]int]*big.Int) // beta[{initiator,responder}]
+	nu := make(map[[2]int]*big.Int)
+	for i, resp := range parties {
+		for j := range parties {
+			if i == j {
+				continue
+			}
+			betaPrime, err := randScalar()
+			if err != nil {
+				return types.Signature{}, err
+			}
+			nuPrime, err := randScalar()
+			if err != nil {
+				return types.Signature{}, err
+			}
+			cGamma, proofGamma, err := mtaRespond(initPaillier[j], initEncK[j], resp.gamma, betaPrime)
+			if err != nil {
+				return types.Signature{}, err
+			}
+			cW, proofW, err := mtaRespond(initPaillier[j], initEncK[j], resp.w, nuPrime)
+			if err != nil {
+				return types.Signature{}, err
+			}
+			payload := encodeByteList([][]byte{cGamma.Bytes(), proofGamma.encode(), cW.Bytes(), proofW.encode()})
+			if err := resp.tr.Send(roundMtAResp, partyID(ids, j), payload); err != nil {
+				return types.Signature{}, err
+			}
+			beta[[2]int{j, i}] = new(big.Int).Mod(new(big.Int).Neg(betaPrime), s256.N)
+			nu[[2]int{j, i}] = new(big.Int).Mod(new(big.Int).Neg(nuPrime), s256.N)
+		}
+	}
+
+	// Round 3: every party, as MtA initiator, decrypts its responses and
+	// folds them (plus its own diagonal term) into delta_i and sigma_i.
+	deltaShares := make([]*big.Int, t)
+	sigmaShares := make([]*big.Int, t)
+	for i, p := range parties {
+		delta := new(big.Int).Mod(new(big.Int).Mul(p.k, p.gamma), s256.N)
+		sigma := new(big.Int).Mod(new(big.Int).Mul(p.k, p.w), s256.N)
+		for j := range parties {
+			if i == j {
+				continue
+			}
+			raw, err := p.tr.Recv(roundMtAResp, partyID(ids, j))
+			if err != nil {
+				return types.Signature{}, err
+			}
+			parts := decodeByteList(raw)
+			if len(parts) != 4 {
+				return types.Signature{}, ErrMtARangeProof
+			}
+			cGamma, cW := new(big.Int).SetBytes(parts[0]), new(big.Int).SetBytes(parts[2])
+			proofGamma, err := decodeMtARangeProof(parts[1])
+			if err != nil {
+				return types.Signature{}, err
+			}
+			proofW, err := decodeMtARangeProof(parts[3])
+			if err != nil {
+				return types.Signature{}, err
+			}
+			if !proofGamma.verify(initPaillier[i], initEncK[i], cGamma) || !proofW.verify(initPaillier[i], initEncK[i], cW) {
+				return types.Signature{}, ErrMtARangeProof
+			}
+			alpha, err := p.paillier.Decrypt(cGamma)
+			if err != nil {
+				return types.Signature{}, err
+			}
+			mu, err := p.paillier.Decrypt(cW)
+			if err != nil {
+				return types.Signature{}, err
+			}
+			delta.Add(delta, alpha)
+			delta.Add(delta, beta[[2]int{i, j}])
+			sigma.Add(sigma, mu)
+			sigma.Add(sigma, nu[[2]int{i, j}])
+		}
+		deltaShares[i] = delta.Mod(delta, s256.N)
+		sigmaShares[i] = sigma.Mod(sigma, s256.N)
+		if err := p.tr.Broadcast(roundDelta, deltaShares[i].FillBytes(make([]byte, 32))); err != nil {
+			return types.Signature{}, err
+		}
+	}
+
+	// delta = k*gamma is safe to reveal: gamma is a one-time random blind,
+	// so it leaks nothing about k. Combine it and every Gamma_i to recover
+	// the public nonce point R = delta^-1 * sum(Gamma_i), without any party
+	// ever learning k or gamma themselves.
+	delta := new(big.Int)
+	var gammaX, gammaY *big.Int
+	for i := range parties {
+		raw, err := parties[0].tr.RecvBroadcast(roundDelta, partyID(ids, i))
+		if err != nil {
+			return types.Signature{}, err
+		}
+		delta.Add(delta, new(big.Int).SetBytes(raw))
+		gx, gy, err := decompressPoint(gammaPoints[i])
+		if err != nil {
+			return types.Signature{}, err
+		}
+		if gammaX == nil {
+			gammaX, gammaY = gx, gy
+		} else {
+			gammaX, gammaY = s256.Add(gammaX, gammaY, gx, gy)
+		}
+	}
+	delta.Mod(delta, s256.N)
+	deltaInv := new(big.Int).ModInverse(delta, s256.N)
+	if deltaInv == nil {
+		return types.Signature{}, ErrSignRecovery
+	}
+	rX, _ := s256.ScalarMult(gammaX, gammaY, deltaInv.Bytes())
+	r := new(big.Int).Mod(rX, s256.N)
+	if r.Sign() == 0 {
+		return types.Signature{}, ErrSignRecovery
+	}
+
+	// Round 4: every party computes its signature share s_i = hash*k_i +
+	// r*sigma_i and broadcasts it; s is their sum, exactly as in a
+	// non-threshold ECDSA signature, so combining it is safe.
+	e := new(big.Int).Mod(new(big.Int).SetBytes(hash.Bytes()), s256.N)
+	for i, p := range parties {
+		si := new(big.Int).Mul(e, p.k)
+		si.Add(si, new(big.Int).Mul(r, sigmaShares[i]))
+		si.Mod(si, s256.N)
+		if err := p.tr.Broadcast(roundSigma, si.FillBytes(make([]byte, 32))); err != nil {
+			return types.Signature{}, err
+		}
+	}
+	s := new(big.Int)
+	for i := range parties {
+		raw, err := parties[0].tr.RecvBroadcast(roundSigma, partyID(ids, i))
+		if err != nil {
+			return types.Signature{}, err
+		}
+		s.Add(s, new(big.Int).SetBytes(raw))
+	}
+	s.Mod(s, s256.N)
+	if s.Sign() == 0 {
+		return types.Signature{}, ErrSignRecovery
+	}
+
+	return buildSignature(addr, e, r, s)
+}
+
+// mtaRespond computes the responder's half of an MtA exchange: given the
+// initiator's Paillier-encrypted secret encA under pubA, and this party's
+// own secret b, it returns Enc_A(a*b + offset), so that the initiator's
+// decryption (a*b + offset) and the negated offset the responder keeps (see
+// Sign) sum to a*b, along with an mtaRangeProof that the initiator can check
+// before decrypting.
+func mtaRespond(pubA *paillierPublicKey, encA *big.Int, b, offset *big.Int) (*big.Int, *mtaRangeProof, error) {
+	c := pubA.HomomorphicScalarMul(encA, b)
+	encOffset, offsetRand, err := pubA.EncryptRandom(offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := pubA.HomomorphicAdd(c, encOffset)
+	proof, err := proveMtARange(pubA, encA, result, b, offset, offsetRand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, proof, nil
+}
+
+// buildSignature assembles a 65-byte (r, s, v) signature, normalizing s to
+// the curve's lower half and finding the recovery id v that recovers addr
+// from (hash, r, s), as Ethereum requires.
+func buildSignature(addr types.Address, e, r, s *big.Int) (types.Signature, error) {
+	halfN := new(big.Int).Rsh(s256.N, 1)
+	for recID := 0; recID < 2; recID++ {
+		candS, candRecID := new(big.Int).Set(s), recID
+		if candS.Cmp(halfN) > 0 {
+			candS.Sub(s256.N, candS)
+			candRecID ^= 1
+		}
+		if recoversTo(addr, e, r, candS, candRecID) {
+			var sig types.Signature
+			copy(sig[0:32], r.FillBytes(make([]byte, 32)))
+			copy(sig[32:64], candS.FillBytes(make([]byte, 32)))
+			sig[64] = byte(candRecID)
+			return sig, nil
+		}
+	}
+	return types.Signature{}, ErrSignRecovery
+}
+
+// recoversTo reports whether recovering a public key from (e, r, s, recID)
+// yields addr.
+func recoversTo(addr types.Address, e, r, s *big.Int, recID int) bool {
+	rx, ry, err := decompressPointFromX(r, recID&1 == 1)
+	if err != nil {
+		return false
+	}
+	rInv := new(big.Int).ModInverse(r, s256.N)
+	if rInv == nil {
+		return false
+	}
+	u1 := new(big.Int).Mod(new(big.Int).Neg(new(big.Int).Mul(e, rInv)), s256.N)
+	u2 := new(big.Int).Mod(new(big.Int).Mul(s, rInv), s256.N)
+	x1, y1 := scalarBaseMult(u1)
+	x2, y2 := s256.ScalarMult(rx, ry, u2.Bytes())
+	qx, qy := s256.Add(x1, y1, x2, y2)
+	return pubKeyToAddress(qx, qy) == addr
+}
+
+// decompressPointFromX recovers the secp256k1 point with the given x
+// coordinate and y parity (yOdd), as used during ECDSA public key recovery.
+func decompressPointFromX(x *big.Int, yOdd bool) (px, py *big.Int, err error) {
+	p := s256.P
+	y2 := new(big.Int).Exp(x, big.NewInt(3), p)
+	y2.Add(y2, s256.B)
+	y2.Mod(y2, p)
+	// p % 4 == 3 for secp256k1, so y = y2^((p+1)/4) mod p is a square root
+	// of y2 whenever one exists.
+	exp := new(big.Int).Rsh(new(big.Int).Add(p, big.NewInt(1)), 2)
+	y := new(big.Int).Exp(y2, exp, p)
+	if new(big.Int).Exp(y, big.NewInt(2), p).Cmp(y2) != 0 {
+		return nil, nil, ErrSignRecovery
+	}
+	if y.Bit(0) == 1 != yOdd {
+		y.Sub(p, y)
+	}
+	return x, y, nil
+}
+
+// scalarBaseMult returns k*G.
+func scalarBaseMult(k *big.Int) (x, y *big.Int) {
+	_, pub := btcec.PrivKeyFromBytes(s256, k.FillBytes(make([]byte, 32)))
+	return pub.X, pub.Y
+}
+
+// scalarBaseMultCompressed returns the SEC1-compressed encoding of k*G.
+func scalarBaseMultCompressed(k *big.Int) []byte {
+	_, pub := btcec.PrivKeyFromBytes(s256, k.FillBytes(make([]byte, 32)))
+	return pub.SerializeCompressed()
+}
+
+// partyID returns the party ID at index i within ids.
+func partyID(ids []uint32, i int) int {
+	return int(ids[i])
+}
+
+// randScalar returns a random scalar in [1, N).
+func randScalar() (*big.Int, error) {
+	for {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		x := new(big.Int).SetBytes(b)
+		if x.Sign() != 0 && x.Cmp(s256.N) < 0 {
+			return x, nil
+		}
+	}
+}
+
+// pubKeyToAddress derives the Ethereum address for a secp256k1 point.
+func pubKeyToAddress(x, y *big.Int) types.Address {
+	return crypto.PublicKeyToAddress(&ecdsa.PublicKey{Curve: s256, X: x, Y: y})
+}
+
+// decompressPoint parses a SEC1 compressed public key into its coordinates.
+func decompressPoint(compressed []byte) (x, y *big.Int, err error) {
+	pub, err := btcec.ParsePubKey(compressed, s256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pub.X, pub.Y, nil
+}
+
+// encodeByteList concatenates a list of byte slices, each prefixed with its
+// big-endian uint32 length, so it can be sent as a single Transport payload.
+func encodeByteList(items [][]byte) []byte {
+	var out []byte
+	for _, item := range items {
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], uint32(len(item)))
+		out = append(out, l[:]...)
+		out = append(out, item...)
+	}
+	return out
+}
+
+// decodeByteList reverses encodeByteList.
+func decodeByteList(raw []byte) [][]byte {
+	var out [][]byte
+	for len(raw) > 0 {
+		l := binary.BigEndian.Uint32(raw[:4])
+		out = append(out, raw[4:4+l])
+		raw = raw[4+l:]
+	}
+	return out
+}
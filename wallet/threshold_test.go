@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/defiweb/go-eth/crypto"
+)
+
+func TestGenerateThresholdKeys_InvalidThreshold(t *testing.T) {
+	transports := NewInProcessTransports(3)
+	if _, err := GenerateThresholdKeys(0, 3, transports); err != ErrInvalidThreshold {
+		t.Errorf("t=0: got %v, want ErrInvalidThreshold", err)
+	}
+	if _, err := GenerateThresholdKeys(4, 3, transports); err != ErrInvalidThreshold {
+		t.Errorf("t=4, n=3: got %v, want ErrInvalidThreshold", err)
+	}
+	if _, err := GenerateThresholdKeys(2, 3, transports[:2]); err != ErrInvalidThreshold {
+		t.Errorf("len(transports)=2, n=3: got %v, want ErrInvalidThreshold", err)
+	}
+}
+
+// TestThresholdSign runs a full 2-of-3 Feldman-VSS DKG and MtA-based sign
+// over NewInProcessTransports, and checks that the resulting signature
+// recovers to the joint key's address, using every eligible 2-party subset.
+func TestThresholdSign(t *testing.T) {
+	const n, threshold = 3, 2
+	transports := NewInProcessTransports(n)
+	keys, err := GenerateThresholdKeys(threshold, n, transports)
+	if err != nil {
+		t.Fatalf("GenerateThresholdKeys: %v", err)
+	}
+	if len(keys) != n {
+		t.Fatalf("got %d keys, want %d", len(keys), n)
+	}
+	for _, k := range keys {
+		if k.address != keys[0].address {
+			t.Fatalf("party %d has a different joint address", k.ID)
+		}
+	}
+
+	hash := crypto.Keccak256([]byte("threshold signing test"))
+
+	subsets := [][2]int{{0, 1}, {0, 2}, {1, 2}}
+	for _, subset := range subsets {
+		i, j := subset[0], subset[1]
+		signKeys := []*ThresholdKey{keys[i], keys[j]}
+		signTransports := []Transport{transportByID(transports, keys[i].ID), transportByID(transports, keys[j].ID)}
+
+		sig, err := Sign(hash, signKeys, signTransports)
+		if err != nil {
+			t.Fatalf("Sign with parties %d,%d: %v", keys[i].ID, keys[j].ID, err)
+		}
+		addr, err := crypto.Ecrecover(hash, sig)
+		if err != nil {
+			t.Fatalf("Ecrecover with parties %d,%d: %v", keys[i].ID, keys[j].ID, err)
+		}
+		if addr != keys[0].address {
+			t.Errorf("parties %d,%d: recovered address %x, want %x", keys[i].ID, keys[j].ID, addr, keys[0].address)
+		}
+	}
+}
+
+func TestThresholdSign_NotEnoughParties(t *testing.T) {
+	const n, threshold = 3, 2
+	transports := NewInProcessTransports(n)
+	keys, err := GenerateThresholdKeys(threshold, n, transports)
+	if err != nil {
+		t.Fatalf("GenerateThresholdKeys: %v", err)
+	}
+	hash := crypto.Keccak256([]byte("not enough parties"))
+	_, err = Sign(hash, keys[:1], []Transport{transportByID(transports, keys[0].ID)})
+	if err != ErrThresholdMismatch {
+		t.Errorf("got %v, want ErrThresholdMismatch", err)
+	}
+}
+
+func transportByID(transports []Transport, id int) Transport {
+	for _, tr := range transports {
+		if tr.ID() == id {
+			return tr
+		}
+	}
+	return nil
+}
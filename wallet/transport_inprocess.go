@@ -0,0 +1,68 @@
+package wallet
+
+// inProcessHub holds the messages exchanged by a set of in-process
+// transports. GenerateThresholdKeys and Sign drive every party from a
+// single goroutine, round by round, so no locking is needed: by the time a
+// party's Recv/RecvBroadcast call for a round runs, every Send/Broadcast for
+// that round has already happened.
+type inProcessHub struct {
+	broadcasts map[string]map[int][]byte    // round -> from -> payload
+	direct     map[string]map[[2]int][]byte // round -> [to, from] -> payload
+}
+
+// inProcessTransport is a Transport backed by an inProcessHub shared with
+// its sibling transports.
+type inProcessTransport struct {
+	id  int
+	hub *inProcessHub
+}
+
+// NewInProcessTransports returns n Transports, with IDs 1..n, that deliver
+// messages to each other in-memory. It is intended for tests and
+// single-process use; a real deployment wires Transport to a network layer
+// instead.
+func NewInProcessTransports(n int) []Transport {
+	hub := &inProcessHub{
+		broadcasts: make(map[string]map[int][]byte),
+		direct:     make(map[string]map[[2]int][]byte),
+	}
+	transports := make([]Transport, n)
+	for i := 0; i < n; i++ {
+		transports[i] = &inProcessTransport{id: i + 1, hub: hub}
+	}
+	return transports
+}
+
+func (t *inProcessTransport) ID() int { return t.id }
+
+func (t *inProcessTransport) Broadcast(round string, payload []byte) error {
+	if t.hub.broadcasts[round] == nil {
+		t.hub.broadcasts[round] = make(map[int][]byte)
+	}
+	t.hub.broadcasts[round][t.id] = payload
+	return nil
+}
+
+func (t *inProcessTransport) RecvBroadcast(round string, from int) ([]byte, error) {
+	payload, ok := t.hub.broadcasts[round][from]
+	if !ok {
+		return nil, ErrTransportNoMessage
+	}
+	return payload, nil
+}
+
+func (t *inProcessTransport) Send(round string, to int, payload []byte) error {
+	if t.hub.direct[round] == nil {
+		t.hub.direct[round] = make(map[[2]int][]byte)
+	}
+	t.hub.direct[round][[2]int{to, t.id}] = payload
+	return nil
+}
+
+func (t *inProcessTransport) Recv(round string, from int) ([]byte, error) {
+	payload, ok := t.hub.direct[round][[2]int{t.id, from}]
+	if !ok {
+		return nil, ErrTransportNoMessage
+	}
+	return payload, nil
+}
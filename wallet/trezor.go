@@ -0,0 +1,383 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/karalabe/hid"
+
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// trezorVendorID is the USB vendor ID assigned to SatoshiLabs (Trezor).
+const trezorVendorID = 0x534c
+
+// Trezor Wire Protocol message type numbers, as assigned by trezor-common's
+// messages.proto. Unlike Ledger, a Trezor device does not speak APDU: every
+// request and response is a length-prefixed protobuf message identified by
+// one of these numbers, framed over HID by writeTrezorMessage/
+// readTrezorMessage.
+const (
+	trezorMsgFailure         = 3
+	trezorMsgButtonRequest   = 26
+	trezorMsgButtonAck       = 27
+	trezorMsgEthereumGetAddr = 56
+	trezorMsgEthereumAddr    = 57
+	trezorMsgEthereumSignTx  = 58
+	trezorMsgEthereumTxReq   = 59
+	trezorMsgEthereumTxAck   = 60
+	trezorMsgEthereumSignMsg = 64
+	trezorMsgEthereumMsgSig  = 66
+)
+
+// trezorReportSize is the fixed HID report size used by the Trezor Wire
+// Protocol.
+const trezorReportSize = 64
+
+// trezorMaxInitialData bounds how much transaction data SignTransaction will
+// send in the initial EthereumSignTx message. Data beyond this would require
+// streaming further chunks in response to EthereumTxRequest, which this
+// client does not yet implement.
+const trezorMaxInitialData = 1024
+
+// ErrTrezorDataTooLarge is returned by TrezorKey.SignTransaction when tx.Data
+// does not fit in a single EthereumSignTx message.
+var ErrTrezorDataTooLarge = errors.New("wallet: transaction data too large for trezor client")
+
+// ErrUnsupportedTransactionType is returned by TrezorKey.SignTransaction for
+// any tx.Type other than legacy (0). The EthereumSignTx message this client
+// sends only carries a legacy transaction's fields (gas_price, no access
+// list); EIP-1559 and EIP-2930 transactions need different, type-specific
+// fields (max_fee_per_gas/max_priority_fee_per_gas, access_list) that this
+// client does not yet encode, so it refuses rather than silently signing an
+// incomplete message. LedgerKey.SignTransaction has no such limitation: it
+// streams tx's own RLP encoding, which already accounts for its type.
+var ErrUnsupportedTransactionType = errors.New("wallet: trezor client only supports legacy transactions")
+
+// TrezorKey is a Key backed by a connected Trezor hardware wallet running
+// the Ethereum app. Unlike LedgerKey, it does not speak APDU: requests and
+// responses are protobuf messages exchanged over the Trezor Wire Protocol.
+type TrezorKey struct {
+	device  hidDevice
+	path    []uint32
+	address types.Address
+}
+
+// OpenTrezor opens the Trezor device at path (as reported by
+// github.com/karalabe/hid.Enumerate) and reads the Ethereum address for the
+// given BIP-32 derivation path, e.g. "m/44'/60'/0'/0/0".
+func OpenTrezor(path string) (*TrezorKey, error) {
+	indices, err := parseBIP32Path(path)
+	if err != nil {
+		return nil, err
+	}
+	var info *hid.DeviceInfo
+	for _, d := range hid.Enumerate(trezorVendorID, 0) {
+		if d.Path == path {
+			info = &d
+			break
+		}
+	}
+	if info == nil {
+		return nil, ErrDeviceNotFound
+	}
+	dev, err := info.Open()
+	if err != nil {
+		return nil, err
+	}
+	k := &TrezorKey{device: dev, path: indices}
+	addr, err := k.getAddress(indices)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	k.address = addr
+	return k, nil
+}
+
+// Address implements the Key interface.
+func (k *TrezorKey) Address() types.Address {
+	return k.address
+}
+
+// Close releases the underlying USB HID device.
+func (k *TrezorKey) Close() error {
+	return k.device.Close()
+}
+
+// getAddress sends an EthereumGetAddress message for path and parses the
+// returned EthereumAddress message.
+func (k *TrezorKey) getAddress(path []uint32) (types.Address, error) {
+	resp, err := k.call(trezorMsgEthereumGetAddr, pbPackedUint32Field(1, path))
+	if err != nil {
+		return types.Address{}, err
+	}
+	addrField, ok := pbReadFields(resp)[2]
+	if !ok {
+		return types.Address{}, ErrDeviceResponse
+	}
+	addrBytes, err := hex.DecodeString(strings.TrimPrefix(string(addrField), "0x"))
+	if err != nil || len(addrBytes) != types.AddressLength {
+		return types.Address{}, ErrDeviceResponse
+	}
+	var addr types.Address
+	copy(addr[:], addrBytes)
+	return addr, nil
+}
+
+// SignHash implements the Key interface, except it always fails: unlike
+// PrivateKey.SignHash, which signs hash directly, the Trezor Ethereum app
+// has no message to sign an arbitrary digest blind. Wrapping hash as a
+// personal message and signing that instead, as an earlier version of this
+// method did, produces a signature over a different message than the one
+// requested — silently incompatible with PrivateKey.SignHash and anything
+// that verifies against the original hash (a precomputed transaction hash or
+// EIP-712 digest, for example). See ErrRawHashSigningUnsupported.
+func (k *TrezorKey) SignHash(hash types.Hash) (types.Signature, error) {
+	return types.Signature{}, ErrRawHashSigningUnsupported
+}
+
+// SignMessage implements the Key interface.
+func (k *TrezorKey) SignMessage(data []byte) (types.Signature, error) {
+	req := append(pbPackedUint32Field(1, k.path), pbBytesField(2, data)...)
+	resp, err := k.call(trezorMsgEthereumSignMsg, req)
+	if err != nil {
+		return types.Signature{}, err
+	}
+	sigField, ok := pbReadFields(resp)[1]
+	if !ok {
+		return types.Signature{}, ErrDeviceResponse
+	}
+	return decodeRecoverableSignature(sigField)
+}
+
+// SignTransaction implements the Key interface for legacy transactions
+// only; see ErrUnsupportedTransactionType. It sends tx as a single
+// EthereumSignTx message; transactions whose data does not fit in one
+// message (see trezorMaxInitialData) are rejected with
+// ErrTrezorDataTooLarge rather than silently truncated.
+func (k *TrezorKey) SignTransaction(tx *types.Transaction) error {
+	if tx.ChainID == nil {
+		return ErrMissingChainID
+	}
+	if tx.Type != 0 {
+		return ErrUnsupportedTransactionType
+	}
+	if len(tx.Data) > trezorMaxInitialData {
+		return ErrTrezorDataTooLarge
+	}
+	req := pbPackedUint32Field(1, k.path)
+	req = append(req, pbBytesField(2, tx.Nonce.Bytes())...)
+	req = append(req, pbBytesField(3, tx.GasPrice.Bytes())...)
+	req = append(req, pbUint32Field(4, uint32(tx.GasLimit))...)
+	req = append(req, pbStringField(5, hex.EncodeToString(tx.To.Bytes()))...)
+	req = append(req, pbBytesField(6, tx.Value.Bytes())...)
+	req = append(req, pbBytesField(7, tx.Data)...)
+	req = append(req, pbUint32Field(8, uint32(len(tx.Data)))...)
+	req = append(req, pbUint32Field(9, uint32(tx.ChainID.Uint64()))...)
+
+	resp, err := k.call(trezorMsgEthereumSignTx, req)
+	if err != nil {
+		return err
+	}
+	fields := pbReadFields(resp)
+	remaining, _ := pbReadVarint(fields[1])
+	if remaining > 0 {
+		return ErrTrezorDataTooLarge
+	}
+	v, _ := pbReadVarint(fields[2])
+	r := fields[3]
+	s := fields[4]
+	if len(r) == 0 || len(s) == 0 {
+		return ErrDeviceResponse
+	}
+	var sig types.Signature
+	copy(sig[32-len(r):32], r)
+	copy(sig[64-len(s):64], s)
+	sig[64] = byte(v)
+	addr := k.Address()
+	tx.From = &addr
+	tx.Signature = &sig
+	return nil
+}
+
+// VerifyHash implements the Key interface, recovering against hash directly,
+// the same as PrivateKey.VerifyHash — it verifies a signature against a raw
+// digest regardless of which Key produced it, so it does not mirror
+// SignHash's refusal.
+func (k *TrezorKey) VerifyHash(hash types.Hash, sig types.Signature) bool {
+	addr, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return false
+	}
+	return addr == k.address
+}
+
+// VerifyMessage implements the Key interface.
+func (k *TrezorKey) VerifyMessage(data []byte, sig types.Signature) bool {
+	addr, err := crypto.EcrecoverMessage(data, sig)
+	if err != nil {
+		return false
+	}
+	return addr == k.address
+}
+
+// call sends a single request message and returns the payload of the first
+// non-ButtonRequest reply, acknowledging any ButtonRequest prompts (the
+// device asking the user to confirm on-screen) along the way.
+func (k *TrezorKey) call(msgType uint16, payload []byte) ([]byte, error) {
+	if err := writeTrezorMessage(k.device, msgType, payload); err != nil {
+		return nil, err
+	}
+	for {
+		respType, resp, err := readTrezorMessage(k.device)
+		if err != nil {
+			return nil, err
+		}
+		switch respType {
+		case trezorMsgButtonRequest:
+			if err := writeTrezorMessage(k.device, trezorMsgButtonAck, nil); err != nil {
+				return nil, err
+			}
+		case trezorMsgFailure:
+			return nil, ErrUserRejected
+		default:
+			return resp, nil
+		}
+	}
+}
+
+// writeTrezorMessage frames payload as msgType using the Trezor Wire
+// Protocol and writes it to dev in trezorReportSize packets. The first
+// packet starts with the marker "?##" followed by a 2-byte message type and
+// a 4-byte message length; every following packet starts with "?".
+func writeTrezorMessage(dev hidDevice, msgType uint16, payload []byte) error {
+	header := []byte{'?', '#', '#', byte(msgType >> 8), byte(msgType), byte(len(payload) >> 24), byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload))}
+	buf := append(header, payload...)
+	for offset := 0; offset == 0 || offset < len(buf); {
+		packet := make([]byte, trezorReportSize)
+		if offset == 0 {
+			n := copy(packet, buf)
+			offset += n
+			if _, err := dev.Write(packet); err != nil {
+				return err
+			}
+			continue
+		}
+		packet[0] = '?'
+		n := copy(packet[1:], buf[offset:])
+		offset += n
+		if _, err := dev.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTrezorMessage reassembles a Trezor Wire Protocol message from one or
+// more trezorReportSize packets.
+func readTrezorMessage(dev hidDevice) (uint16, []byte, error) {
+	packet := make([]byte, trezorReportSize)
+	if _, err := dev.Read(packet); err != nil {
+		return 0, nil, err
+	}
+	if len(packet) < 9 || packet[0] != '?' || packet[1] != '#' || packet[2] != '#' {
+		return 0, nil, ErrDeviceResponse
+	}
+	msgType := uint16(packet[3])<<8 | uint16(packet[4])
+	want := int(packet[5])<<24 | int(packet[6])<<16 | int(packet[7])<<8 | int(packet[8])
+	data := append([]byte{}, packet[9:]...)
+	for len(data) < want {
+		packet = make([]byte, trezorReportSize)
+		if _, err := dev.Read(packet); err != nil {
+			return 0, nil, err
+		}
+		if len(packet) < 1 || packet[0] != '?' {
+			return 0, nil, ErrDeviceResponse
+		}
+		data = append(data, packet[1:]...)
+	}
+	return msgType, data[:want], nil
+}
+
+// The following encode/decode a small, fixed subset of the protobuf wire
+// format: varints and length-delimited fields, which is all the Ethereum
+// messages used above require.
+
+func pbTag(field int, wireType int) []byte {
+	return pbVarint(uint64(field<<3 | wireType))
+}
+
+func pbVarint(v uint64) []byte {
+	var out []byte
+	for v >= 0x80 {
+		out = append(out, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(out, byte(v))
+}
+
+func pbReadVarint(b []byte) (uint64, int) {
+	var v uint64
+	var shift uint
+	for i, c := range b {
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	return 0, len(b)
+}
+
+func pbUint32Field(field int, v uint32) []byte {
+	return append(pbTag(field, 0), pbVarint(uint64(v))...)
+}
+
+func pbBytesField(field int, b []byte) []byte {
+	out := append(pbTag(field, 2), pbVarint(uint64(len(b)))...)
+	return append(out, b...)
+}
+
+func pbStringField(field int, s string) []byte {
+	return pbBytesField(field, []byte(s))
+}
+
+func pbPackedUint32Field(field int, vs []uint32) []byte {
+	var payload []byte
+	for _, v := range vs {
+		payload = append(payload, pbVarint(uint64(v))...)
+	}
+	return pbBytesField(field, payload)
+}
+
+// pbReadFields decodes a flat (non-nested) protobuf message into a map from
+// field number to its raw value bytes: for a varint field, the value
+// re-encoded as a varint; for a length-delimited field, the field's content.
+func pbReadFields(data []byte) map[int][]byte {
+	fields := make(map[int][]byte)
+	for len(data) > 0 {
+		tag, n := pbReadVarint(data)
+		data = data[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			v, n := pbReadVarint(data)
+			data = data[n:]
+			fields[field] = pbVarint(v)
+		case 2:
+			l, n := pbReadVarint(data)
+			data = data[n:]
+			if uint64(len(data)) < l {
+				return fields
+			}
+			fields[field] = data[:l]
+			data = data[l:]
+		default:
+			return fields
+		}
+	}
+	return fields
+}
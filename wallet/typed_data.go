@@ -0,0 +1,30 @@
+package wallet
+
+import (
+	"github.com/defiweb/go-eth/abi"
+	"github.com/defiweb/go-eth/crypto"
+	"github.com/defiweb/go-eth/types"
+)
+
+// SignTypedData signs typedData according to EIP-712.
+func (k *PrivateKey) SignTypedData(typedData *types.TypedData) (types.Signature, error) {
+	hash, err := abi.HashTypedData(typedData)
+	if err != nil {
+		return types.Signature{}, err
+	}
+	return k.SignHash(hash)
+}
+
+// VerifyTypedData reports whether sig is a valid EIP-712 signature of
+// typedData by this key.
+func (k *PrivateKey) VerifyTypedData(typedData *types.TypedData, sig types.Signature) bool {
+	hash, err := abi.HashTypedData(typedData)
+	if err != nil {
+		return false
+	}
+	addr, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return false
+	}
+	return addr == k.address
+}
@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/defiweb/go-eth/types"
+)
+
+func sampleTypedData(contents string) *types.TypedData {
+	return &types.TypedData{
+		Domain: types.TypedDataDomain{
+			Name:    "Ether Mail",
+			Version: "1",
+			ChainID: big.NewInt(1),
+		},
+		PrimaryType: "Mail",
+		Types: types.TypedDataTypes{
+			"Mail": {
+				{Name: "contents", Type: "string"},
+			},
+		},
+		Message: map[string]any{
+			"contents": contents,
+		},
+	}
+}
+
+func TestSignTypedData_VerifyRoundTrip(t *testing.T) {
+	priv := NewRandomKey()
+	td := sampleTypedData("Hello, Bob!")
+
+	sig, err := priv.SignTypedData(td)
+	if err != nil {
+		t.Fatalf("SignTypedData: %v", err)
+	}
+	if !priv.VerifyTypedData(td, sig) {
+		t.Error("VerifyTypedData = false for the key's own signature")
+	}
+
+	other := NewRandomKey()
+	if other.VerifyTypedData(td, sig) {
+		t.Error("VerifyTypedData = true for a different key")
+	}
+}
+
+func TestVerifyTypedData_RejectsChangedMessage(t *testing.T) {
+	priv := NewRandomKey()
+	td := sampleTypedData("Hello, Bob!")
+
+	sig, err := priv.SignTypedData(td)
+	if err != nil {
+		t.Fatalf("SignTypedData: %v", err)
+	}
+
+	tampered := sampleTypedData("Hello, Eve!")
+	if priv.VerifyTypedData(tampered, sig) {
+		t.Error("VerifyTypedData = true after the message changed")
+	}
+}